@@ -56,9 +56,11 @@ func NewKnownHosts(path string) (*KnownHosts, error) {
 // This implementation returns nil if the certificate in the known hosts list is expired, replacing the version in the file.
 // Users should check for the Error: if it isn't gemini.ErrCert then it was an issue in saving the file, and will likely happen again on repeat attempts.
 // Note that a zero value for expiry means no expiry. This lets you permanently trust certificates by manipulating the known hosts file by hand.
+// Hosts are normalized, so that the Unicode and punycode spellings of a host share an entry.
 func (r *KnownHosts) VerifyCert(host string, certs []*x509.Certificate) error {
+	host = gemini.NormalizeHost(host)
 	if val, ok := r.hosts[host]; ok {
-		if !(val.Expiry.IsZero() || val.Expiry.After(time.Now())) { // not expired
+		if val.Expiry.IsZero() || val.Expiry.After(time.Now()) { // not expired
 			//if tn := time.Now(); !tn.After(val.Expiry) { // it's not expired, check fingerprint
 			fc := Fingerprint(certs[0]) // only consider the leaf certificate
 			if fc != val.Fingerprint {
@@ -78,7 +80,7 @@ func (r *KnownHosts) Load() error {
 	}
 
 	r.hosts = make(map[string]Host) // drop old cache
-	err = json.Unmarshal(b, r.hosts)
+	err = json.Unmarshal(b, &r.hosts)
 	return err
 }
 
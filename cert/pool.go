@@ -6,12 +6,17 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
+	"fmt"
 	"io/ioutil"
 	"math/big"
+	"net"
 	"os"
 	"path"
 	"strings"
+	"sync"
 	"time"
+
+	"toast.cafe/x/gemini"
 )
 
 // certTemplate is a baseline template used to generate self-signed certs
@@ -36,9 +41,24 @@ const validLength = time.Hour * 24 * 60
 // Note that this pool is biased towards usage for gemini servers.
 // Feel free to adapt it to your own needs, though, as it is licensed under the unlicense!
 type Pool struct {
-	store string
-	certs map[string]*tls.Certificate
-	files []os.FileInfo
+	// HostPolicy restricts the names GetCertificate will serve and generate certificates for.
+	//
+	// It gets the normalized server name, and refuses it by returning an error, see AllowHosts.
+	// If it is nil, all names are allowed.
+	HostPolicy func(string) error
+
+	store   string
+	mu      sync.Mutex // guards certs and pending
+	certs   map[string]*tls.Certificate
+	pending map[string]*fetch // names being loaded or generated
+	files   []os.FileInfo
+}
+
+// fetch is an in-progress load or generation, that other callers wait for
+type fetch struct {
+	done chan struct{}
+	cert *tls.Certificate
+	err  error
 }
 
 // NewStore will open the given directory, creating it if needed.
@@ -52,6 +72,8 @@ func NewStore(directory string) (*Pool, error) {
 	}
 	var pool Pool
 	pool.store = directory
+	pool.certs = make(map[string]*tls.Certificate)
+	pool.pending = make(map[string]*fetch)
 	err = pool.reparseDir()
 	return &pool, err
 }
@@ -70,7 +92,10 @@ func OpenStore(directory string) (c *Pool, err error) {
 		name := v.Name()
 		suff := path.Ext(name)
 		if suff == ".key" { // only look at keys, directory might also store known hosts
-			c.load(strings.TrimSuffix(name, suff)) // ignore err, we just continue
+			name = strings.TrimSuffix(name, suff)
+			if cert, err := c.load(name); err == nil { // ignore err, we just continue
+				c.certs[name] = cert
+			}
 		}
 	}
 
@@ -85,29 +110,110 @@ func OpenStore(directory string) (c *Pool, err error) {
 // 2. if there is no cached cert, try to load one from the store, and check for expiry on success (go to 4).
 // 3. if there is no cert in the store, generate one and save it in the store. return it.
 // 4. if the cert is expired, goto 3, else return it
+//
+// Concurrent calls for the same name wait for a single load or generation, calls for other names don't wait.
 func (c *Pool) Get(name string) (*tls.Certificate, error) {
-	if cert, ok := c.certs[name]; ok {
-		if !expired(cert) {
-			return cert, nil
+	return c.get(name, serverTemplate(name))
+}
+
+// serverTemplate returns the template of a server certificate for name
+func serverTemplate(name string) *x509.Certificate {
+	tmpl := certTemplate // copy
+	if ip := net.ParseIP(name); ip != nil {
+		tmpl.IPAddresses = []net.IP{ip}
+	} else {
+		tmpl.DNSNames = []string{name}
+	}
+	return &tmpl
+}
+
+// GetTemplate is like Get, but certificates are generated from tmpl instead of the default server template.
+//
+// The serial number and validity period of tmpl are filled in, and the name is only used as the key.
+// This allows storing client identities in a pool, see for example misfin.Identity.
+func (c *Pool) GetTemplate(name string, tmpl *x509.Certificate) (*tls.Certificate, error) {
+	return c.get(name, tmpl)
+}
+
+// GetCertificate gets the certificate for the server name of the ClientHello, for use as tls.Config.GetCertificate.
+//
+// Server names are normalized (see gemini.NormalizeHost).
+// Clients that don't send a server name (such as those connecting to an ip address) get the certificate of the local address.
+//
+// Certificates for new names are generated on first contact, unless HostPolicy refuses them.
+// Set a HostPolicy on servers reachable by untrusted clients,
+// as anyone could otherwise fill the store and keep the server busy generating keys by making up server names.
+func (c *Pool) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := hello.ServerName
+	if name == "" && hello.Conn != nil {
+		name, _, _ = net.SplitHostPort(hello.Conn.LocalAddr().String())
+	}
+	name = gemini.NormalizeHost(name)
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("%w: invalid server name %q", gemini.ErrCert, name)
+	}
+	if c.HostPolicy != nil {
+		if err := c.HostPolicy(name); err != nil {
+			return nil, err
 		}
-		// it's expired
-		if err := c.generate(name); err != nil {
-			return nil, err // we have failed
+	}
+	return c.Get(name)
+}
+
+// AllowHosts returns a HostPolicy that only allows the given hosts
+func AllowHosts(hosts ...string) func(string) error {
+	allowed := make(map[string]bool, len(hosts))
+	for _, v := range hosts {
+		allowed[gemini.NormalizeHost(v)] = true
+	}
+	return func(host string) error {
+		if !allowed[host] {
+			return fmt.Errorf("%w: host %q is not allowed", gemini.ErrCert, host)
 		}
-		return c.Get(name)
+		return nil
 	}
+}
 
-	err := c.load(name)
-	if err == nil {
-		return c.Get(name)
+// get returns the certificate for name, loading it from the store, or generating it from tmpl
+//
+// Only one load or generation per name happens at a time, the other callers wait for its result.
+func (c *Pool) get(name string, tmpl *x509.Certificate) (*tls.Certificate, error) {
+	c.mu.Lock()
+	if cert, ok := c.certs[name]; ok && !expired(cert) {
+		c.mu.Unlock()
+		return cert, nil
 	}
+	if f, ok := c.pending[name]; ok {
+		c.mu.Unlock()
+		<-f.done
+		return f.cert, f.err
+	}
+	f := &fetch{done: make(chan struct{})}
+	c.pending[name] = f
+	c.mu.Unlock()
+
+	f.cert, f.err = c.fetch(name, tmpl)
 
-	err = c.generate(name)
-	if err == nil {
-		return c.Get(name)
+	c.mu.Lock()
+	delete(c.pending, name)
+	if f.err == nil {
+		c.certs[name] = f.cert
 	}
+	c.mu.Unlock()
+	close(f.done)
+	return f.cert, f.err
+}
 
-	return nil, err
+// fetch loads the certificate for name from the store, generating it first if it's missing or expired
+func (c *Pool) fetch(name string, tmpl *x509.Certificate) (*tls.Certificate, error) {
+	cert, err := c.load(name)
+	if err == nil && !expired(cert) {
+		return cert, nil
+	}
+	if err := c.generate(name, tmpl); err != nil {
+		return nil, err
+	}
+	return c.load(name)
 }
 
 func leaf(cert *tls.Certificate) (*x509.Certificate, error) {
@@ -130,7 +236,7 @@ func expired(cert *tls.Certificate) bool {
 	return now.After(exp)
 }
 
-func (c *Pool) generate(name string) error {
+func (c *Pool) generate(name string, base *x509.Certificate) error {
 	// serial number
 	serialMax := new(big.Int).Lsh(big.NewInt(1), 128)
 	serial, err := rand.Int(rand.Reader, serialMax)
@@ -143,11 +249,10 @@ func (c *Pool) generate(name string) error {
 		return err // TODO: could not generate ed25519 key
 	}
 
-	tmpl := certTemplate // copy
+	tmpl := *base // copy
 	tmpl.SerialNumber = serial
 	tmpl.NotBefore = time.Now()
 	tmpl.NotAfter = tmpl.NotBefore.Add(validLength)
-	tmpl.DNSNames = []string{name}
 
 	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, pub, priv)
 	if err != nil {
@@ -184,17 +289,15 @@ func (c *Pool) generate(name string) error {
 	return nil
 }
 
-func (c *Pool) load(name string) error {
+func (c *Pool) load(name string) (*tls.Certificate, error) {
 	keypath := path.Join(c.store, name+".key")
 	certpath := path.Join(c.store, name+".pem")
 
 	cert, err := tls.LoadX509KeyPair(certpath, keypath)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	c.certs[name] = &cert
-	return nil
+	return &cert, nil
 }
 
 func (c *Pool) reparseDir() (err error) {
@@ -0,0 +1,86 @@
+package cert_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"toast.cafe/x/gemini/cert"
+)
+
+func testPool(t *testing.T) (*cert.Pool, string) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "cert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	pool, err := cert.NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pool, dir
+}
+
+func TestGetCertificate(t *testing.T) {
+	pool, dir := testPool(t)
+
+	// without a policy, any name is generated
+	if _, err := pool.GetCertificate(&tls.ClientHelloInfo{ServerName: "made.up.example"}); err != nil {
+		t.Errorf("expected a certificate without a HostPolicy, instead found %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "made.up.example.key")); err != nil {
+		t.Errorf("expected a key in the store, instead found %v", err)
+	}
+	known, err := pool.Get("known.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, err := pool.GetCertificate(&tls.ClientHelloInfo{ServerName: "Known.Example."}); err != nil || c != known {
+		t.Errorf("expected the existing certificate, instead found %v", err)
+	}
+	for _, v := range []string{"../escape", ".hidden", `a\b`} {
+		if _, err := pool.GetCertificate(&tls.ClientHelloInfo{ServerName: v}); err == nil {
+			t.Errorf("%s: expected an error for a bad server name", v)
+		}
+	}
+
+	// with one, allowed names are generated
+	pool.HostPolicy = cert.AllowHosts("allowed.example")
+	c, err := pool.GetCertificate(&tls.ClientHelloInfo{ServerName: "allowed.example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf, err := x509.ParseCertificate(c.Certificate[0]); err != nil || len(leaf.DNSNames) != 1 || leaf.DNSNames[0] != "allowed.example" {
+		t.Errorf("expected a certificate for allowed.example, instead found %v", err)
+	}
+	if _, err := pool.GetCertificate(&tls.ClientHelloInfo{ServerName: "denied.example"}); err == nil {
+		t.Error("expected an error for a name refused by the HostPolicy")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "denied.example.key")); err == nil {
+		t.Error("expected no key for a refused name")
+	}
+}
+
+func TestGetConcurrent(t *testing.T) {
+	pool, _ := testPool(t)
+	certs := make([]*tls.Certificate, 8)
+	var wg sync.WaitGroup
+	for i := range certs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			certs[i], _ = pool.Get("example.org")
+		}(i)
+	}
+	wg.Wait()
+	for _, v := range certs {
+		if v == nil || v != certs[0] {
+			t.Fatal("expected all callers to get the same certificate")
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package gemini
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/transform"
+)
+
+// lookupCharset finds the encoding for a charset name, returning nil for utf-8 and its subsets
+func lookupCharset(charset string) (encoding.Encoding, error) {
+	switch strings.ToLower(charset) {
+	case "", "utf-8", "utf8", "us-ascii", "ascii":
+		return nil, nil // nothing to do
+	}
+	enc, err := ianaindex.IANA.Encoding(charset)
+	if err != nil || enc == nil { // try the more lenient web names before giving up
+		enc, err = htmlindex.Get(charset)
+	}
+	if err != nil || enc == nil {
+		return nil, fmt.Errorf("%w: %q", ErrCharset, charset)
+	}
+	return enc, nil
+}
+
+// NewDecodingReader returns a reader that transcodes r from the given charset to utf-8
+//
+// Unknown charsets result in ErrCharset.
+func NewDecodingReader(r io.Reader, charset string) (io.Reader, error) {
+	enc, err := lookupCharset(charset)
+	if err != nil || enc == nil {
+		return r, err
+	}
+	return transform.NewReader(r, enc.NewDecoder()), nil
+}
+
+// responseCharset determines the charset of the body
+func (r *Response) responseCharset() (string, error) {
+	mt, err := r.MediaType()
+	if err != nil {
+		return "", err
+	}
+	// ParseMediaType fills in utf-8 when there's no charset, which is never transcoded,
+	// so non-text types without an explicit charset are passed through as they should be
+	return mt.Charset, nil
+}
+
+// DecodedReader returns a reader over the body, transcoded to utf-8 according to the charset parameter of the meta
+//
+// Non-text media types without an explicit charset are passed through untouched.
+// Note that reading from it counts as calling Read().
+func (r *Response) DecodedReader() (io.Reader, error) {
+	cs, err := r.responseCharset()
+	if err != nil {
+		return nil, err
+	}
+	return NewDecodingReader(r, cs)
+}
+
+// DecodedBody returns the body of the response transcoded to utf-8, see Body() and DecodedReader()
+func (r *Response) DecodedBody() (string, error) {
+	cs, err := r.responseCharset()
+	if err != nil {
+		return "", err
+	}
+	enc, err := lookupCharset(cs)
+	if err != nil {
+		return "", err
+	}
+	body, err := r.Body()
+	if err != nil || enc == nil {
+		return body, err
+	}
+	return enc.NewDecoder().String(body)
+}
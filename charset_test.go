@@ -0,0 +1,48 @@
+package gemini_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"toast.cafe/x/gemini"
+)
+
+func TestDecodedBody(t *testing.T) {
+	for _, v := range []struct {
+		raw    string
+		expect string
+		err    error
+	}{
+		{"20 text/plain; charset=iso-8859-1\r\ncaf\xe9", "café", nil},
+		{"20 text/plain; charset=Shift_JIS\r\n\x93\xfa\x96\x7b", "日本", nil},
+		{"20 text/gemini\r\ncafé", "café", nil},
+		{"20 image/png\r\n\xe9", "\xe9", nil},
+		{"20 application/x-latin; charset=iso-8859-1\r\ncaf\xe9", "café", nil},
+		{"20 text/plain; charset=x-made-up\r\ncafé", "", gemini.ErrCharset},
+	} {
+		var r gemini.Response
+		r.FromReader(strings.NewReader(v.raw))
+		body, err := r.DecodedBody()
+		if !errors.Is(err, v.err) {
+			t.Errorf("expected error %q, instead found %q", v.err, err)
+		}
+		if err == nil && body != v.expect {
+			t.Errorf("expected body %q, instead found %q", v.expect, body)
+		}
+	}
+}
+
+func TestDecodedReader(t *testing.T) {
+	var r gemini.Response
+	r.FromReader(strings.NewReader("20 text/plain; charset=latin1\r\ncaf\xe9"))
+	rdr, err := r.DecodedReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(rdr)
+	if string(body) != "café" {
+		t.Errorf("expected body %q, instead found %q", "café", body)
+	}
+}
@@ -13,6 +13,14 @@ type Ctx struct {
 
 	ClientCerts []*x509.Certificate // server only
 	ServerCerts []*x509.Certificate // client only
+
+	// Titan is set for titan uploads, in which case Req is its target with the parameters stripped.
+	Titan *TitanRequest
+	// Body is the body of a titan upload, limited to its size.
+	Body io.Reader
+
+	// Session is per-user state attached by server middleware, see gms.Sessions and gms.SessionFrom.
+	Session interface{}
 }
 
 // NewRequestCtx constructs a request context from a string
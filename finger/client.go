@@ -0,0 +1,119 @@
+package finger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strings"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/gms"
+)
+
+// DefaultMaxSize is the largest answer a Client reads by default
+const DefaultMaxSize = 1 << 20
+
+// Client is a finger client
+type Client struct {
+	Dialer net.Dialer
+
+	// MaxSize is the largest answer read, DefaultMaxSize if zero.
+	MaxSize int64
+}
+
+// DefaultClient is the default
+var DefaultClient = &Client{}
+
+// Do sends the query to the server at addr (host or host:port), and returns the answer
+//
+// Line endings are normalized to "\n".
+func (c *Client) Do(addr string, q *Query) (string, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, DefaultPort)
+	}
+	con, err := c.Dialer.Dial("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	defer con.Close()
+
+	if _, err := fmt.Fprintf(con, "%s\r\n", q); err != nil {
+		return "", err
+	}
+	max := c.MaxSize
+	if max <= 0 {
+		max = DefaultMaxSize
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(con, max))
+	return strings.ReplaceAll(string(b), "\r\n", "\n"), err
+}
+
+// Finger queries "user@host", the last host being the one contacted, the way the finger command does
+func (c *Client) Finger(s string) (string, error) {
+	q, err := ParseQuery(s)
+	if err != nil {
+		return "", err
+	}
+	if len(q.Hosts) == 0 {
+		return "", fmt.Errorf("%w: no host", ErrQuery)
+	}
+	host := q.Hosts[len(q.Hosts)-1]
+	q.Hosts = q.Hosts[:len(q.Hosts)-1]
+	return c.Do(host, q)
+}
+
+// Fetch queries a finger URL, finger://host[:port][/user]
+func (c *Client) Fetch(s string) (string, error) {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != "finger" || u.Host == "" {
+		return "", fmt.Errorf("%w: expected an absolute finger url", ErrURL)
+	}
+	q, err := ParseQuery(strings.TrimPrefix(u.Path, "/"))
+	if err != nil {
+		return "", err
+	}
+	addr := u.Host
+	if u.Port() == "" { // Do would bracket an ipv6 host again
+		addr = net.JoinHostPort(u.Hostname(), DefaultPort)
+	}
+	return c.Do(addr, q)
+}
+
+// ProxyHandler returns a Handler that serves finger:// proxy requests as text/plain
+//
+// Requests for other schemes are refused.
+// If client is nil, DefaultClient is used.
+//
+// Loopback and private addresses are refused too, see gms.PublicAddressesOnly, unless the client's dialer has its own Control function.
+// Only set one if the handler is not reachable by untrusted users, or as an allow list: an open proxy exposes the server's network.
+func ProxyHandler(client *Client) gms.HandlerFunc {
+	if client == nil {
+		client = DefaultClient
+	}
+	c := *client
+	c.Dialer = gms.ProxyDialer(c.Dialer)
+	client = &c
+	return func(ctx *gemini.Ctx) {
+		if ctx.Req.URL.Scheme != "finger" {
+			ctx.Res.Status = gemini.StatusProxyRequestRefused
+			ctx.Res.SetMeta("only finger is proxied")
+			return
+		}
+		text, err := client.Fetch(ctx.Req.URL.String())
+		if errors.Is(err, gms.ErrForbiddenAddress) {
+			ctx.Res.Status = gemini.StatusProxyRequestRefused
+			ctx.Res.SetMeta("refusing to proxy to a non-public address")
+			return
+		} else if err != nil {
+			ctx.Res.Status = gemini.StatusProxyError
+			ctx.Res.SetMeta("upstream request failed")
+			return
+		}
+		ctx.Res.Status = gemini.StatusSuccess
+		ctx.Res.SetMeta("text/plain; charset=utf-8")
+		ctx.Res.WriteString(text)
+	}
+}
@@ -0,0 +1,125 @@
+// Package finger implements the finger protocol (RFC 1288), with a server, a client, and a gemini proxy handler.
+package finger
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"toast.cafe/x/gemini/gms"
+)
+
+type fingerError string
+
+func (e fingerError) Error() string { return string(e) }
+
+// Finger errors
+const (
+	ErrQuery = fingerError("invalid finger query")
+	ErrURL   = fingerError("invalid finger url")
+)
+
+// DefaultPort is the default finger port
+const DefaultPort = "79"
+
+// Query is a finger query
+//
+// An empty User lists the users of the server, Hosts asks the server to forward the query (user@host1@host2).
+type Query struct {
+	User    string
+	Hosts   []string
+	Verbose bool // the /W switch
+}
+
+// ParseQuery parses a query line, without its terminator
+func ParseQuery(line string) (*Query, error) {
+	q := new(Query)
+	line = strings.TrimLeft(line, " ")
+	if strings.HasPrefix(line, "/W") {
+		q.Verbose = true
+		line = strings.TrimLeft(line[2:], " ")
+	}
+	if strings.ContainsAny(line, " \t\r\n") {
+		return nil, fmt.Errorf("%w: unexpected whitespace", ErrQuery)
+	}
+	parts := strings.Split(line, "@")
+	q.User = parts[0]
+	for _, v := range parts[1:] {
+		if v == "" {
+			return nil, fmt.Errorf("%w: empty host", ErrQuery)
+		}
+		q.Hosts = append(q.Hosts, v)
+	}
+	return q, nil
+}
+
+// String returns the query line, without its terminator
+func (q *Query) String() string {
+	var b strings.Builder
+	if q.Verbose {
+		b.WriteString("/W ")
+	}
+	b.WriteString(q.User)
+	for _, v := range q.Hosts {
+		b.WriteByte('@')
+		b.WriteString(v)
+	}
+	return b.String()
+}
+
+// A Handler responds to a finger query.
+//
+// ServeFinger should write the answer, as plain text, to w.
+type Handler interface {
+	ServeFinger(w io.Writer, q *Query)
+}
+
+// HandlerFunc is an adapter that allows using standalone functions as finger Handlers
+type HandlerFunc func(io.Writer, *Query)
+
+// ServeFinger calls f(w, q)
+func (f HandlerFunc) ServeFinger(w io.Writer, q *Query) {
+	f(w, q)
+}
+
+// Server is a finger server
+//
+// Forwarded queries (user@host) are refused, as RFC 1288 recommends.
+type Server struct {
+	// TCP address to listen on, defaults to :79
+	Addr    string
+	Handler Handler
+	Logger  gms.Logger
+}
+
+func (s *Server) line() *gms.LineServer {
+	addr := s.Addr
+	if addr == "" {
+		addr = ":" + DefaultPort
+	}
+	return &gms.LineServer{Addr: addr, Handler: gms.LineHandlerFunc(s.ServeLine), Logger: s.Logger}
+}
+
+// Serve listens on Addr and serves requests
+func (s *Server) Serve() error {
+	return s.line().Serve()
+}
+
+// ServeListener serves requests from an existing listener
+func (s *Server) ServeListener(l net.Listener) error {
+	return s.line().ServeListener(l)
+}
+
+// ServeLine answers a single query line, see gms.LineHandler
+func (s *Server) ServeLine(w io.Writer, line string) {
+	q, err := ParseQuery(line)
+	switch {
+	case err != nil:
+		fmt.Fprintf(w, "%s\r\n", err)
+	case len(q.Hosts) > 0:
+		io.WriteString(w, "finger forwarding denied\r\n")
+	default:
+		s.Handler.ServeFinger(w, q)
+	}
+}
@@ -0,0 +1,112 @@
+package finger_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/finger"
+)
+
+func TestParseQuery(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want finger.Query
+		err  bool
+	}{
+		{"", finger.Query{}, false},
+		{"alice", finger.Query{User: "alice"}, false},
+		{"/W alice", finger.Query{User: "alice", Verbose: true}, false},
+		{"/W", finger.Query{Verbose: true}, false},
+		{"alice@a.example@b.example", finger.Query{User: "alice", Hosts: []string{"a.example", "b.example"}}, false},
+		{"@a.example", finger.Query{Hosts: []string{"a.example"}}, false},
+		{"alice bob", finger.Query{}, true},
+		{"alice@", finger.Query{}, true},
+	} {
+		got, err := finger.ParseQuery(tc.in)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%q: expected an error", tc.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", tc.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(*got, tc.want) {
+			t.Errorf("%q: got %+v, want %+v", tc.in, *got, tc.want)
+		}
+		if s := got.String(); s != tc.in && tc.in != "/W" {
+			t.Errorf("%q: round trip gave %q", tc.in, s)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen:", err)
+	}
+	defer l.Close()
+	srv := &finger.Server{Handler: finger.HandlerFunc(func(w io.Writer, q *finger.Query) {
+		fmt.Fprintf(w, "user %q verbose %v\r\n", q.User, q.Verbose)
+	})}
+	go srv.ServeListener(l)
+	addr := l.Addr().String()
+
+	c := finger.DefaultClient
+	for _, tc := range []struct {
+		get  func() (string, error)
+		want string
+	}{
+		{func() (string, error) { return c.Do(addr, &finger.Query{User: "alice"}) }, "user \"alice\" verbose false\n"},
+		{func() (string, error) { return c.Fetch("finger://" + addr + "/bob") }, "user \"bob\" verbose false\n"},
+		{func() (string, error) { return c.Fetch("finger://" + addr) }, "user \"\" verbose false\n"},
+		{func() (string, error) { return c.Do(addr, &finger.Query{User: "x", Verbose: true}) }, "user \"x\" verbose true\n"},
+		{func() (string, error) { return c.Do(addr, &finger.Query{User: "x", Hosts: []string{"elsewhere"}}) }, "finger forwarding denied\n"},
+	} {
+		got, err := tc.get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("got %q, want %q", got, tc.want)
+		}
+	}
+
+	ctx, _ := gemini.NewRequestCtx("finger://" + addr + "/carol")
+	ctx.Res = new(gemini.Response)
+	finger.ProxyHandler(nil)(ctx)
+	if ctx.Status() != gemini.StatusProxyRequestRefused {
+		t.Errorf("proxy: got status %d for a loopback address, want %d", ctx.Status(), gemini.StatusProxyRequestRefused)
+	}
+
+	ctx, _ = gemini.NewRequestCtx("finger://" + addr + "/carol")
+	ctx.Res = new(gemini.Response)
+	ctx.Res.ServerPrepare()
+	finger.ProxyHandler(&finger.Client{Dialer: net.Dialer{Control: allowAll}})(ctx)
+	ctx.Res.Flush()
+	if body, _ := ctx.Res.Body(); ctx.Status() != gemini.StatusSuccess || ctx.Meta() != "text/plain; charset=utf-8" || body != "user \"carol\" verbose false\n" {
+		t.Errorf("proxy: got %q %q", ctx.Header(), body)
+	}
+}
+
+func TestFetchIPv6(t *testing.T) {
+	var dialed string
+	c := &finger.Client{Dialer: net.Dialer{Control: func(network, address string, _ syscall.RawConn) error {
+		dialed = address
+		return errors.New("not dialing")
+	}}}
+	c.Fetch("finger://[::1]/alice")
+	if dialed != "[::1]:79" {
+		t.Errorf("expected to dial %q, instead found %q", "[::1]:79", dialed)
+	}
+}
+
+func allowAll(network, address string, c syscall.RawConn) error { return nil }
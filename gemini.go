@@ -6,11 +6,32 @@ func (e geminiError) Error() string { return string(e) }
 
 // Gemini errors
 const (
-	ErrCert    = geminiError("certificate error")
-	ErrFlush   = geminiError("already flushed")
-	ErrHeader  = geminiError("invalid header")
-	ErrRead    = geminiError("already called read")
-	ErrRequest = geminiError("invalid request")
+	ErrCert      = geminiError("certificate error")
+	ErrCharset   = geminiError("unsupported charset")
+	ErrCommitted = geminiError("header already sent")
+	ErrFlush     = geminiError("already flushed")
+	ErrHeader    = geminiError("invalid header")
+	ErrMediaType = geminiError("invalid media type")
+	ErrRead      = geminiError("already called read")
+	ErrRequest   = geminiError("invalid request")
+	ErrStatus    = geminiError("unsuccessful status")
+	ErrStream    = geminiError("response is not streamable")
+)
+
+// HeaderError is a reason for a response header to be rejected
+//
+// All HeaderErrors match ErrHeader with errors.Is.
+type HeaderError string
+
+func (e HeaderError) Error() string { return string(e) }
+
+// Is makes HeaderError match ErrHeader
+func (e HeaderError) Is(target error) bool { return target == ErrHeader }
+
+// Response header errors
+const (
+	ErrHeaderTooLong   = HeaderError("header too long")
+	ErrHeaderTruncated = HeaderError("header truncated")
 )
 
 // Status represents a gemini status code
@@ -19,29 +40,29 @@ type Status int
 // Gemini status codes, spec canonical names
 const (
 	StatusInput          Status = 10
-	StatusInputSensitive        = 11
+	StatusInputSensitive Status = 11
 
-	StatusSuccess = 20
+	StatusSuccess Status = 20
 
-	StatusRedirect          = 30
-	StatusRedirectTemporary = 30
-	StatusRedirectPermanent = 31
+	StatusRedirect          Status = 30
+	StatusRedirectTemporary Status = 30
+	StatusRedirectPermanent Status = 31
 
-	StatusTemporaryFailure  = 40
-	StatusServerUnavailable = 41
-	StatusCGIError          = 42
-	StatusProxyError        = 43
-	StatusSlowDown          = 44
+	StatusTemporaryFailure  Status = 40
+	StatusServerUnavailable Status = 41
+	StatusCGIError          Status = 42
+	StatusProxyError        Status = 43
+	StatusSlowDown          Status = 44
 
-	StatusPermanentFailure    = 50
-	StatusNotFound            = 51
-	StatusGone                = 52
-	StatusProxyRequestRefused = 53
-	StatusBadRequest          = 59
+	StatusPermanentFailure    Status = 50
+	StatusNotFound            Status = 51
+	StatusGone                Status = 52
+	StatusProxyRequestRefused Status = 53
+	StatusBadRequest          Status = 59
 
-	StatusClientCertificateRequires = 60
-	StatusCertificateNotAuthorized  = 61
-	StatusCertificateNotValid       = 62
+	StatusClientCertificateRequires Status = 60
+	StatusCertificateNotAuthorized  Status = 61
+	StatusCertificateNotValid       Status = 62
 )
 
 const MaxMeta = 1024
@@ -0,0 +1,150 @@
+// Package gemtext implements the text/gemini document format.
+package gemtext
+
+import "strings"
+
+// MediaType is the media type of gemtext documents
+const MediaType = "text/gemini"
+
+var (
+	_ Line = Text("")
+	_ Line = (*Link)(nil)
+	_ Line = (*Heading)(nil)
+	_ Line = ListItem("")
+	_ Line = Quote("")
+	_ Line = (*PreformatToggle)(nil)
+	_ Line = PreformattedText("")
+	_ Line = (*Preformatted)(nil)
+)
+
+// A Line is a single typed element of a gemtext document.
+//
+// String returns the element in gemtext syntax, without the trailing newline.
+// Note that a Preformatted block spans several lines of text.
+type Line interface {
+	String() string
+	line() // restricts implementations to this package
+}
+
+// Document is a parsed gemtext document
+type Document []Line
+
+// String returns the document in gemtext syntax
+func (d Document) String() string {
+	var b strings.Builder
+	for _, v := range d {
+		b.WriteString(v.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Links returns all of the link lines in the document, in order
+func (d Document) Links() []*Link {
+	var out []*Link
+	for _, v := range d {
+		if l, ok := v.(*Link); ok {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// Title returns the text of the first heading in the document, if any
+func (d Document) Title() string {
+	for _, v := range d {
+		if h, ok := v.(*Heading); ok {
+			return h.Text
+		}
+	}
+	return ""
+}
+
+// Text is a plain text line
+type Text string
+
+func (t Text) String() string { return string(t) }
+func (Text) line()            {}
+
+// Link is a link line
+type Link struct {
+	URL   string
+	Label string // optional
+}
+
+func (l *Link) String() string {
+	if l.Label == "" {
+		return "=> " + l.URL
+	}
+	return "=> " + l.URL + " " + l.Label
+}
+func (*Link) line() {}
+
+// Heading is a heading line of Level 1, 2 or 3
+type Heading struct {
+	Level int
+	Text  string
+}
+
+func (h *Heading) String() string {
+	level := h.Level
+	switch {
+	case level < 1:
+		level = 1
+	case level > 3:
+		level = 3
+	}
+	return strings.Repeat("#", level) + " " + h.Text
+}
+func (*Heading) line() {}
+
+// ListItem is an unordered list item line
+type ListItem string
+
+func (l ListItem) String() string { return "* " + string(l) }
+func (ListItem) line()            {}
+
+// Quote is a quote line
+type Quote string
+
+func (q Quote) String() string { return "> " + string(q) }
+func (Quote) line()            {}
+
+// PreformatToggle is a preformatting toggle line
+//
+// It is only produced by the Scanner, Parse groups preformatted lines into a Preformatted block instead.
+// Alt is only meaningful on the toggle that opens a block.
+type PreformatToggle struct {
+	Alt string
+}
+
+func (p *PreformatToggle) String() string { return "```" + p.Alt }
+func (*PreformatToggle) line()            {}
+
+// PreformattedText is a line within a preformatted block
+//
+// It is only produced by the Scanner, Parse groups preformatted lines into a Preformatted block instead.
+type PreformattedText string
+
+func (p PreformattedText) String() string { return string(p) }
+func (PreformattedText) line()            {}
+
+// Preformatted is a complete preformatted block, including its toggles
+type Preformatted struct {
+	Alt   string
+	Lines []string
+}
+
+func (p *Preformatted) String() string {
+	var b strings.Builder
+	b.WriteString("```")
+	b.WriteString(p.Alt)
+	b.WriteByte('\n')
+	for _, v := range p.Lines {
+		b.WriteString(v)
+		b.WriteByte('\n')
+	}
+	b.WriteString("```")
+	return b.String()
+}
+func (*Preformatted) line() {}
@@ -0,0 +1,184 @@
+package gemtext
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"net/url"
+	"strings"
+
+	"toast.cafe/x/gemini"
+)
+
+// ErrMediaType is returned when rendering a response that isn't gemtext
+const ErrMediaType = gemtextError("not a text/gemini response")
+
+// DefaultHTMLTemplate is the page shell used by an HTMLRenderer with no Template
+var DefaultHTMLTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html{{with .Lang}} lang="{{.}}"{{end}}>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+</head>
+<body>
+{{.Body}}</body>
+</html>
+`))
+
+// HTMLPage is the data passed to the page shell template of an HTMLRenderer
+type HTMLPage struct {
+	Title string        // the first heading of the document
+	Lang  string        // copied from the renderer
+	Body  template.HTML // the rendered document
+}
+
+// HTMLRenderer renders gemtext documents as semantic HTML5
+//
+// All text is escaped.
+// Consecutive list items are grouped into a <ul>, consecutive quote lines into a <blockquote>.
+// Preformatted blocks become a <pre>, with the alt text (if any) as its aria-label.
+type HTMLRenderer struct {
+	// RewriteLink, if non-nil, is called on every link url before it is written out.
+	RewriteLink func(string) string
+
+	// Template is executed with an HTMLPage to produce the full page.
+	//
+	// If nil, DefaultHTMLTemplate is used.
+	Template *template.Template
+
+	// Lang is the language of the page, passed through to the template.
+	Lang string
+
+	// Fragment makes Render only write the body, skipping the template.
+	Fragment bool
+}
+
+// Render renders the document to w
+func (h *HTMLRenderer) Render(w io.Writer, d Document) error {
+	var body bytes.Buffer
+	h.renderBody(&body, d)
+	if h.Fragment {
+		_, err := body.WriteTo(w)
+		return err
+	}
+
+	tmpl := h.Template
+	if tmpl == nil {
+		tmpl = DefaultHTMLTemplate
+	}
+	return tmpl.Execute(w, HTMLPage{
+		Title: d.Title(),
+		Lang:  h.Lang,
+		Body:  template.HTML(body.String()),
+	})
+}
+
+// RenderResponse renders the body of a response to w
+//
+// The response must be a success with a text/gemini meta.
+func (h *HTMLRenderer) RenderResponse(w io.Writer, r *gemini.Response) error {
+	mt, err := r.MediaType()
+	if err != nil || !mt.Is(MediaType) {
+		return fmt.Errorf("%w: %d %s", ErrMediaType, r.Status, r.Meta())
+	}
+	d, err := Parse(r)
+	if err != nil {
+		return err
+	}
+	return h.Render(w, d)
+}
+
+func (h *HTMLRenderer) renderBody(b *bytes.Buffer, d Document) {
+	var group string // the element wrapping the current run of lines, if any
+	for _, l := range d {
+		var want string
+		switch l.(type) {
+		case ListItem:
+			want = "ul"
+		case Quote:
+			want = "blockquote"
+		}
+		if group != want {
+			if group != "" {
+				fmt.Fprintf(b, "</%s>\n", group)
+			}
+			if want != "" {
+				fmt.Fprintf(b, "<%s>\n", want)
+			}
+			group = want
+		}
+
+		switch v := l.(type) {
+		case Text:
+			if strings.TrimSpace(string(v)) != "" {
+				fmt.Fprintf(b, "<p>%s</p>\n", html.EscapeString(string(v)))
+			}
+		case *Link:
+			u := v.URL
+			if h.RewriteLink != nil {
+				u = h.RewriteLink(u)
+			}
+			label := v.Label
+			if label == "" {
+				label = v.URL
+			}
+			fmt.Fprintf(b, "<p><a href=\"%s\">%s</a></p>\n", html.EscapeString(safeURL(u)), html.EscapeString(label))
+		case *Heading:
+			fmt.Fprintf(b, "<h%d>%s</h%[1]d>\n", v.Level, html.EscapeString(v.Text))
+		case ListItem:
+			fmt.Fprintf(b, "<li>%s</li>\n", html.EscapeString(string(v)))
+		case Quote:
+			fmt.Fprintf(b, "<p>%s</p>\n", html.EscapeString(string(v)))
+		case *Preformatted:
+			if v.Alt != "" {
+				fmt.Fprintf(b, "<pre aria-label=\"%s\">", html.EscapeString(v.Alt))
+			} else {
+				b.WriteString("<pre>")
+			}
+			b.WriteString(html.EscapeString(strings.Join(v.Lines, "\n")))
+			b.WriteString("</pre>\n")
+		}
+	}
+	if group != "" {
+		fmt.Fprintf(b, "</%s>\n", group)
+	}
+}
+
+// MirrorLinks returns a link rewriter suitable for HTMLRenderer.RewriteLink
+//
+// Absolute gemini:// links to the capsule host are rewritten to point at the same path under mirror (e.g. "https://example.org/").
+// Relative links already resolve against the mirror and are left alone, as are links to other hosts.
+func MirrorLinks(capsule, mirror string) func(string) string {
+	base, err := url.Parse(mirror)
+	return func(s string) string {
+		if err != nil {
+			return s
+		}
+		u, err := url.Parse(s)
+		if err != nil || u.Scheme != "gemini" || !strings.EqualFold(u.Hostname(), capsule) {
+			return s
+		}
+		out := *base
+		out.Path = strings.TrimSuffix(base.Path, "/") + u.Path
+		out.RawPath = ""
+		out.RawQuery = u.RawQuery
+		out.Fragment = u.Fragment
+		return out.String()
+	}
+}
+
+// neuters urls that would execute code in the browser
+func safeURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "#"
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "javascript", "vbscript", "data":
+		return "#"
+	}
+	return s
+}
@@ -0,0 +1,125 @@
+package html2gmi
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/gemtext"
+	"toast.cafe/x/gemini/gms"
+)
+
+// MaxSize is the largest upstream body a Handler reads, anything past it is cut off
+const MaxSize = 16 << 20
+
+// Handler returns a Handler that serves the web site at upstream as gemtext
+//
+// The request path and query are appended to upstream.
+// HTML pages are converted, with links back into upstream rewritten to stay within the proxy, other content is passed through as-is.
+// If client is nil, http.DefaultClient is used.
+func Handler(upstream string, client *http.Client) (gms.HandlerFunc, error) {
+	base, err := url.Parse(upstream)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	prefix := strings.TrimSuffix(base.Path, "/")
+
+	return func(ctx *gemini.Ctx) {
+		target := *base
+		target.Path = path.Clean(prefix + "/" + ctx.Req.URL.Path)
+		if !within(target.Path, prefix) { // .. segments
+			ctx.Res.Status = gemini.StatusBadRequest
+			ctx.Res.SetMeta("path outside of the mirror")
+			return
+		}
+		if strings.HasSuffix(ctx.Req.URL.Path, "/") && !strings.HasSuffix(target.Path, "/") {
+			target.Path += "/"
+		}
+		target.RawPath = ""
+		target.RawQuery = ctx.Req.URL.RawQuery
+
+		res, err := client.Get(target.String())
+		if err != nil {
+			ctx.Res.Status = gemini.StatusProxyError
+			ctx.Res.SetMeta("upstream request failed")
+			return
+		}
+		defer res.Body.Close()
+
+		switch {
+		case res.StatusCode == http.StatusNotFound:
+			ctx.Res.Status = gemini.StatusNotFound
+			ctx.Res.SetMeta(res.Status)
+			return
+		case res.StatusCode == http.StatusGone:
+			ctx.Res.Status = gemini.StatusGone
+			ctx.Res.SetMeta(res.Status)
+			return
+		case res.StatusCode != http.StatusOK:
+			ctx.Res.Status = gemini.StatusProxyError
+			ctx.Res.SetMeta(res.Status)
+			return
+		}
+
+		body := io.LimitReader(res.Body, MaxSize)
+		mt, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+		if mt != "text/html" && mt != "application/xhtml+xml" {
+			ctx.Res.Status = gemini.StatusSuccess
+			ctx.Res.SetMeta(res.Header.Get("Content-Type"))
+			io.Copy(ctx.Res, body)
+			return
+		}
+
+		body, err = charset.NewReader(body, res.Header.Get("Content-Type"))
+		if err != nil {
+			ctx.Res.Status = gemini.StatusProxyError
+			ctx.Res.SetMeta("unsupported upstream charset")
+			return
+		}
+		c := Converter{Base: res.Request.URL}
+		doc, err := c.Convert(body)
+		if err != nil {
+			ctx.Res.Status = gemini.StatusProxyError
+			ctx.Res.SetMeta("could not parse upstream document")
+			return
+		}
+		for _, v := range doc {
+			if l, ok := v.(*gemtext.Link); ok {
+				l.URL = localize(l.URL, base, prefix)
+			}
+		}
+
+		ctx.Res.Status = gemini.StatusSuccess
+		ctx.Res.SetMeta(gemtext.MediaType + "; charset=utf-8")
+		gemtext.NewWriter(ctx.Res).Document(doc)
+	}, nil
+}
+
+// turns absolute links into upstream into absolute paths within the proxy
+//
+// Only same-origin http(s) links whose path lies within prefix are rewritten.
+func localize(link string, base *url.URL, prefix string) string {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Scheme != base.Scheme || u.Host != base.Host || !within(u.Path, prefix) {
+		return link
+	}
+	out := url.URL{Path: strings.TrimPrefix(u.Path, prefix), RawQuery: u.RawQuery, Fragment: u.Fragment}
+	if out.Path == "" {
+		out.Path = "/"
+	}
+	return out.String()
+}
+
+// within returns true if p is prefix or below it, on a path segment boundary
+func within(p, prefix string) bool {
+	return prefix == "" || p == prefix || strings.HasPrefix(p, prefix+"/")
+}
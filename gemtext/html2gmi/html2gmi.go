@@ -0,0 +1,443 @@
+// Package html2gmi converts HTML documents to readable gemtext.
+//
+// Headings are mapped onto the three gemtext levels, block elements become paragraphs, anchors and images become link lines after the paragraph they appear in, and <pre> becomes a preformatted block.
+// Scripts, styles, forms and navigation chrome are dropped.
+package html2gmi
+
+import (
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"toast.cafe/x/gemini/gemtext"
+)
+
+// elements that never contain readable content
+var dropped = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Head:     true,
+	atom.Nav:      true,
+	atom.Aside:    true,
+	atom.Form:     true,
+	atom.Button:   true,
+	atom.Select:   true,
+	atom.Textarea: true,
+	atom.Iframe:   true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Svg:      true,
+	atom.Canvas:   true,
+	atom.Dialog:   true,
+}
+
+// elements that break paragraphs
+var blocks = map[atom.Atom]bool{
+	atom.P:          true,
+	atom.Div:        true,
+	atom.Section:    true,
+	atom.Article:    true,
+	atom.Main:       true,
+	atom.Header:     true,
+	atom.Footer:     true,
+	atom.Address:    true,
+	atom.Figure:     true,
+	atom.Figcaption: true,
+	atom.Details:    true,
+	atom.Summary:    true,
+	atom.Dl:         true,
+	atom.Dt:         true,
+	atom.Dd:         true,
+	atom.Table:      true,
+	atom.Hr:         true,
+	atom.Center:     true,
+}
+
+// Converter converts HTML to gemtext
+//
+// The zero value is ready to use.
+type Converter struct {
+	// Base, if set, is used to resolve relative links.
+	Base *url.URL
+
+	// KeepChrome keeps <header> and <footer> elements that are direct children of <body>.
+	//
+	// They are usually site navigation, so they are dropped by default.
+	KeepChrome bool
+}
+
+// Convert converts an HTML document using the default options
+func Convert(r io.Reader) (gemtext.Document, error) {
+	var c Converter
+	return c.Convert(r)
+}
+
+type list struct {
+	ordered bool
+	n       int
+}
+
+// per-document conversion state
+type state struct {
+	*Converter
+	doc    gemtext.Document
+	lines  []string           // finished lines of the current paragraph
+	text   strings.Builder    // the line being built
+	links  []*gemtext.Link    // links found in the current paragraph
+	labels []*strings.Builder // labels of the open anchors, which can span line breaks
+
+	heading int    // level of the heading being built, if any
+	quote   int    // blockquote depth
+	lists   []list // open lists
+	item    bool   // are we in a list item?
+	cell    bool   // have we already seen a cell in this table row?
+	title   string // contents of <title>
+}
+
+// Convert converts an HTML document
+func (c *Converter) Convert(r io.Reader) (gemtext.Document, error) {
+	root, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	s := state{Converter: c}
+	s.findTitle(root)
+	s.walk(root)
+	s.flush()
+
+	if s.title != "" {
+		if _, ok := firstHeading(s.doc).(*gemtext.Heading); !ok {
+			s.doc = append(gemtext.Document{&gemtext.Heading{Level: 1, Text: s.title}, gemtext.Text("")}, s.doc...)
+		}
+	}
+	return s.doc, nil
+}
+
+func firstHeading(d gemtext.Document) gemtext.Line {
+	for _, v := range d {
+		if h, ok := v.(*gemtext.Heading); ok && h.Level == 1 {
+			return h
+		}
+	}
+	return nil
+}
+
+func (s *state) findTitle(n *html.Node) {
+	if n.Type == html.ElementNode && n.DataAtom == atom.Title {
+		s.title = collapse(textContent(n))
+		return
+	}
+	for c := n.FirstChild; c != nil && s.title == ""; c = c.NextSibling {
+		s.findTitle(c)
+	}
+}
+
+func (s *state) emit(l gemtext.Line) {
+	s.doc = append(s.doc, l)
+}
+
+// separates blocks with a blank line
+func (s *state) sep() {
+	if len(s.doc) > 0 && s.doc[len(s.doc)-1] != gemtext.Text("") {
+		s.emit(gemtext.Text(""))
+	}
+}
+
+// breaks the current line, as for a <br>
+func (s *state) lineBreak() {
+	s.lines = append(s.lines, strings.TrimSpace(s.text.String()))
+	s.text.Reset()
+	for _, v := range s.labels {
+		v.WriteByte(' ')
+	}
+}
+
+// flush emits the current paragraph, heading or list item, followed by its links
+func (s *state) flush() {
+	s.lineBreak()
+	var lines []string
+	for _, v := range s.lines {
+		if v != "" {
+			lines = append(lines, v)
+		}
+	}
+	s.lines = nil
+
+	links := s.links
+	s.links = nil
+	if len(lines) == 0 && len(links) == 0 {
+		return
+	}
+
+	switch {
+	case s.heading > 0:
+		s.sep()
+		s.emit(&gemtext.Heading{Level: s.heading, Text: strings.Join(lines, " ")})
+	case s.item:
+		l := s.lists[len(s.lists)-1]
+		text := strings.Join(lines, " ")
+		if l.ordered {
+			text = strconv.Itoa(l.n) + ". " + text
+		}
+		if len(lines) > 0 {
+			s.emit(gemtext.ListItem(text))
+		}
+	case s.quote > 0:
+		s.sep()
+		for _, v := range lines {
+			s.emit(gemtext.Quote(v))
+		}
+	default:
+		if onlyLinks(lines, links) {
+			lines = nil // the link lines say it all
+		}
+		s.sep()
+		for _, v := range lines {
+			s.emit(gemtext.Text(v))
+		}
+	}
+	for _, v := range links {
+		s.emit(v)
+	}
+}
+
+// returns true if the paragraph consists solely of the labels of its links
+func onlyLinks(lines []string, links []*gemtext.Link) bool {
+	if len(links) == 0 {
+		return false
+	}
+	var labels []string
+	for _, v := range links {
+		labels = append(labels, v.Label)
+	}
+	return collapse(strings.Join(lines, " ")) == collapse(strings.Join(labels, " "))
+}
+
+func (s *state) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		s.writeText(n.Data)
+		return
+	case html.DocumentNode:
+		s.children(n)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if dropped[n.DataAtom] || attr(n, "role") == "navigation" || attr(n, "aria-hidden") == "true" {
+		return
+	}
+	if !s.KeepChrome && (n.DataAtom == atom.Header || n.DataAtom == atom.Footer) && n.Parent != nil && n.Parent.DataAtom == atom.Body {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		s.flush()
+		s.heading = int(n.Data[1] - '0')
+		if s.heading > 3 {
+			s.heading = 3
+		}
+		s.children(n)
+		s.flush()
+		s.heading = 0
+
+	case atom.Pre:
+		s.flush()
+		s.sep()
+		body := strings.TrimPrefix(textContent(n), "\n")
+		body = strings.TrimRight(body, "\n")
+		s.emit(&gemtext.Preformatted{Alt: preAlt(n), Lines: strings.Split(body, "\n")})
+
+	case atom.A:
+		b := new(strings.Builder)
+		s.labels = append(s.labels, b)
+		s.children(n)
+		s.labels = s.labels[:len(s.labels)-1]
+		label := collapse(b.String())
+		if href, ok := s.resolve(attr(n, "href")); ok {
+			if label == "" {
+				label = attr(n, "title")
+			}
+			s.links = append(s.links, &gemtext.Link{URL: href, Label: label})
+		}
+
+	case atom.Img:
+		if src, ok := s.resolve(attr(n, "src")); ok {
+			label := collapse(attr(n, "alt"))
+			if label == "" {
+				label = "image"
+			}
+			s.links = append(s.links, &gemtext.Link{URL: src, Label: label})
+		}
+
+	case atom.Br:
+		s.lineBreak()
+
+	case atom.Ul, atom.Ol:
+		s.flush()
+		if len(s.lists) == 0 {
+			s.sep()
+		}
+		s.lists = append(s.lists, list{ordered: n.DataAtom == atom.Ol})
+		item := s.item
+		s.item = false
+		s.children(n)
+		s.flush()
+		s.item = item
+		s.lists = s.lists[:len(s.lists)-1]
+
+	case atom.Li:
+		s.flush()
+		if len(s.lists) == 0 { // stray <li>
+			s.lists = append(s.lists, list{})
+			defer func() { s.lists = s.lists[:len(s.lists)-1] }()
+		}
+		s.lists[len(s.lists)-1].n++
+		s.item = true
+		s.children(n)
+		s.flush()
+		s.item = false
+
+	case atom.Blockquote:
+		s.flush()
+		s.quote++
+		s.children(n)
+		s.flush()
+		s.quote--
+
+	case atom.Tr:
+		s.flush()
+		s.cell = false
+		s.children(n)
+		s.lineBreak()
+
+	case atom.Td, atom.Th:
+		if s.cell {
+			s.text.WriteString(" | ")
+		}
+		s.cell = true
+		s.children(n)
+
+	default:
+		if blocks[n.DataAtom] {
+			s.flush()
+			s.children(n)
+			s.flush()
+			return
+		}
+		s.children(n)
+	}
+}
+
+func (s *state) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		s.walk(c)
+	}
+}
+
+// writes text with html whitespace collapsing
+func (s *state) writeText(t string) {
+	if t == "" {
+		return
+	}
+	lead := isSpace(t[0])
+	trail := isSpace(t[len(t)-1])
+	t = collapse(t)
+
+	cur := s.text.String()
+	if lead && cur != "" && !strings.HasSuffix(cur, " ") {
+		s.text.WriteByte(' ')
+	}
+	s.text.WriteString(t)
+	if trail && t != "" {
+		s.text.WriteByte(' ')
+	}
+	for _, v := range s.labels { // collapsed in the end
+		if lead {
+			v.WriteByte(' ')
+		}
+		v.WriteString(t)
+		if trail {
+			v.WriteByte(' ')
+		}
+	}
+}
+
+// resolves a link, returning false for links that are useless outside of a browser
+func (s *state) resolve(href string) (string, bool) {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") {
+		return "", false
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "javascript", "data", "vbscript":
+		return "", false
+	}
+	if s.Base != nil {
+		u = s.Base.ResolveReference(u)
+	}
+	return u.String(), true
+}
+
+// picks alt text for a <pre>
+func preAlt(n *html.Node) string {
+	for _, k := range []string{"aria-label", "title"} {
+		if v := attr(n, k); v != "" {
+			return collapse(v)
+		}
+	}
+	// <pre><code class="language-go">
+	for c := n; c != nil; c = c.FirstChild {
+		for _, class := range strings.Fields(attr(c, "class")) {
+			if strings.HasPrefix(class, "language-") {
+				return strings.TrimPrefix(class, "language-")
+			}
+		}
+	}
+	return ""
+}
+
+func attr(n *html.Node, key string) string {
+	for _, v := range n.Attr {
+		if v.Namespace == "" && v.Key == key {
+			return v.Val
+		}
+	}
+	return ""
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.DataAtom == atom.Br {
+			b.WriteByte('\n')
+			continue
+		}
+		b.WriteString(textContent(c))
+	}
+	return b.String()
+}
+
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
@@ -0,0 +1,135 @@
+package html2gmi_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/gemtext/html2gmi"
+)
+
+var testhtml = `<!DOCTYPE html>
+<html>
+<head><title>Page title</title><style>p { color: red }</style></head>
+<body>
+<header><a href="/">Home</a> <a href="/about">About</a></header>
+<nav><a href="/nav">Nav</a></nav>
+<main>
+<p>Some <b>bold</b>
+text with a <a href="/link">link</a>.<br>Second line.</p>
+<h2>Sub<em>heading</em></h2>
+<h5>Deep</h5>
+<script>alert("hi")</script>
+<p><a href="https://other.example/">Only a link</a></p>
+<ul><li>one</li><li>two<ol><li>nested</li></ol></li></ul>
+<blockquote><p>quoted</p></blockquote>
+<pre><code class="language-go">func main() {
+	&lt;-ch
+}</code></pre>
+<img src="pic.png" alt="A picture">
+<a href="javascript:void(0)">nothing</a>
+</main>
+</body>
+</html>`
+
+func TestConvert(t *testing.T) {
+	base, _ := url.Parse("https://example.org/dir/")
+	c := html2gmi.Converter{Base: base}
+	doc, err := c.Convert(strings.NewReader(testhtml))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := "# Page title\n" +
+		"\n" +
+		"Some bold text with a link.\n" +
+		"Second line.\n" +
+		"=> https://example.org/link link\n" +
+		"\n" +
+		"## Subheading\n" +
+		"\n" +
+		"### Deep\n" +
+		"\n" +
+		"=> https://other.example/ Only a link\n" +
+		"\n" +
+		"* one\n" +
+		"* two\n" +
+		"* 1. nested\n" +
+		"\n" +
+		"> quoted\n" +
+		"\n" +
+		"```go\n" +
+		"func main() {\n" +
+		"\t<-ch\n" +
+		"}\n" +
+		"```\n" +
+		"\n" +
+		"nothing\n" +
+		"=> https://example.org/dir/pic.png A picture\n"
+	if doc.String() != expect {
+		t.Errorf("expected %q, instead found %q", expect, doc.String())
+	}
+}
+
+func TestLinkAcrossBreak(t *testing.T) {
+	doc, err := html2gmi.Convert(strings.NewReader(`<p>hello there <a href="x">foo<br>b</a></p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := "hello there foo\nb\n=> x foo b\n"; doc.String() != expect {
+		t.Errorf("expected %q, instead found %q", expect, doc.String())
+	}
+}
+
+func TestHandler(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/blog/big" {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write(make([]byte, html2gmi.MaxSize+1))
+			return
+		}
+		if r.URL.Path != "/blog/page" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=iso-8859-1")
+		io.WriteString(w, "<p>caf\xe9 <a href=\"/blog/a\">a</a> <a href=\"/blogroll\">roll</a> <a href=\"/blog\">home</a></p>")
+	}))
+	defer srv.Close()
+
+	h, err := html2gmi.Handler(srv.URL+"/blog/", srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(p string) *gemini.Ctx {
+		ctx, err := gemini.NewRequestCtx("gemini://mirror.example" + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx.Res = new(gemini.Response)
+		ctx.Res.ServerPrepare()
+		h(ctx)
+		ctx.Res.Flush()
+		return ctx
+	}
+
+	ctx := get("/page")
+	body, _ := ctx.Res.Body()
+	expect := "café a roll home\n=> /a a\n=> " + srv.URL + "/blogroll roll\n=> / home\n"
+	if ctx.Status() != gemini.StatusSuccess || body != expect {
+		t.Errorf("expected %q, instead found %s %q", expect, ctx.Header(), body)
+	}
+
+	ctx = get("/big")
+	if body, _ := ctx.Res.Body(); ctx.Status() != gemini.StatusSuccess || len(body) != html2gmi.MaxSize {
+		t.Errorf("expected the passed through body to be cut at %d bytes, instead found %s with %d", html2gmi.MaxSize, ctx.Header(), len(body))
+	}
+
+	if ctx := get("/../page"); ctx.Status() != gemini.StatusBadRequest {
+		t.Errorf("expected .. to be refused, instead found %s", ctx.Header())
+	}
+}
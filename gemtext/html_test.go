@@ -0,0 +1,62 @@
+package gemtext_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/gemtext"
+)
+
+func TestRenderHTML(t *testing.T) {
+	doc := gemtext.ParseString("# <Title>\n" +
+		"text & more\n" +
+		"* one\n" +
+		"* two\n" +
+		"> quote\n" +
+		"=> gemini://example.org/page Page\n" +
+		"=> javascript:alert(1) bad\n" +
+		"```diagram\n" +
+		"<>\n" +
+		"```\n")
+	h := gemtext.HTMLRenderer{
+		RewriteLink: gemtext.MirrorLinks("example.org", "https://example.org/mirror/"),
+		Fragment:    true,
+	}
+
+	var b strings.Builder
+	if err := h.Render(&b, doc); err != nil {
+		t.Fatal(err)
+	}
+	expect := "<h1>&lt;Title&gt;</h1>\n" +
+		"<p>text &amp; more</p>\n" +
+		"<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n" +
+		"<blockquote>\n<p>quote</p>\n</blockquote>\n" +
+		"<p><a href=\"https://example.org/mirror/page\">Page</a></p>\n" +
+		"<p><a href=\"#\">bad</a></p>\n" +
+		"<pre aria-label=\"diagram\">&lt;&gt;</pre>\n"
+	if b.String() != expect {
+		t.Errorf("expected %q, instead found %q", expect, b.String())
+	}
+}
+
+func TestRenderHTMLResponse(t *testing.T) {
+	var h gemtext.HTMLRenderer
+	var r gemini.Response
+
+	r.FromReader(strings.NewReader("20 text/plain\r\nhi"))
+	if err := h.RenderResponse(new(strings.Builder), &r); !errors.Is(err, gemtext.ErrMediaType) {
+		t.Errorf("expected error %q, instead found %q", gemtext.ErrMediaType, err)
+	}
+
+	r.Reset()
+	r.FromReader(strings.NewReader("20 text/gemini; lang=en\r\n# hi"))
+	var b strings.Builder
+	if err := h.RenderResponse(&b, &r); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "<title>hi</title>") {
+		t.Errorf("expected a title in %q", b.String())
+	}
+}
@@ -0,0 +1,47 @@
+package md2gmi
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/gemtext"
+	"toast.cafe/x/gemini/gms"
+)
+
+// FileHandler returns a Handler that serves the markdown files in dir as gemtext
+//
+// A request for /a/b is served from dir/a/b.md (or dir/a/b if it already ends in .md), and directories are served from their index.md.
+// If c is nil, the default options are used.
+func FileHandler(dir string, c *Converter) gms.HandlerFunc {
+	if c == nil {
+		c = new(Converter)
+	}
+	return func(ctx *gemini.Ctx) {
+		p := path.Clean("/" + ctx.Req.URL.Path) // rooted, so .. can't escape
+		if strings.HasSuffix(ctx.Req.URL.Path, "/") || p == "/" {
+			p = path.Join(p, "index.md")
+		} else if path.Ext(p) != ".md" {
+			p += ".md"
+		}
+
+		src, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(p)))
+		if err != nil {
+			if os.IsNotExist(err) {
+				ctx.Res.Status = gemini.StatusNotFound
+				ctx.Res.SetMeta("not found")
+			} else {
+				ctx.Res.Status = gemini.StatusTemporaryFailure
+				ctx.Res.SetMeta("could not read file")
+			}
+			return
+		}
+
+		ctx.Res.Status = gemini.StatusSuccess
+		ctx.Res.SetMeta(gemtext.MediaType + "; charset=utf-8")
+		gemtext.NewWriter(ctx.Res).Document(c.Convert(src))
+	}
+}
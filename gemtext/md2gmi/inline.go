@@ -0,0 +1,258 @@
+package md2gmi
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ascii punctuation that may be backslash-escaped
+const escapable = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+// escapes emphasis markers, see stripEmphasis
+var codeEscaper = strings.NewReplacer("\x00", "", "*", "\x00*", "_", "\x00_", "~", "\x00~")
+
+// inline reduces inline markdown to plain text, queueing up any links it finds
+func (s *state) inline(src string) string {
+	var b strings.Builder
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\\' && i+1 < len(src) && strings.IndexByte(escapable, src[i+1]) >= 0:
+			b.WriteByte(0) // placeholder, so that escaped emphasis survives
+			b.WriteByte(src[i+1])
+			i += 2
+			continue
+
+		case c == '`':
+			run := runLength(src[i:], '`')
+			if end := strings.Index(src[i+run:], src[i:i+run]); end >= 0 {
+				code := src[i : i+run+end+run]
+				b.WriteString(codeEscaper.Replace(code)) // emphasis markers in code are literal
+				i += len(code)
+				continue
+			}
+			b.WriteString(src[i : i+run])
+			i += run
+			continue
+
+		case c == '<':
+			if end := strings.IndexByte(src[i:], '>'); end > 0 {
+				inner := src[i+1 : i+end]
+				if isAutolink(inner) {
+					b.WriteString(s.link(inner, inner))
+					i += end + 1
+					continue
+				}
+			}
+
+		case c == '!' && i+1 < len(src) && src[i+1] == '[':
+			if text, url, n := s.parseLink(src[i+1:]); n > 0 {
+				b.WriteString(s.link(url, text))
+				i += n + 1
+				continue
+			}
+
+		case c == '[':
+			if text, url, n := s.parseLink(src[i:]); n > 0 {
+				b.WriteString(s.link(url, text))
+				i += n
+				continue
+			}
+		}
+		b.WriteByte(c)
+		i++
+	}
+
+	out := b.String()
+	if !s.KeepEmphasis {
+		out = stripEmphasis(out)
+	}
+	return strings.ReplaceAll(out, "\x00", "")
+}
+
+// link queues a link and returns the text that should stand in for it
+func (s *state) link(url, text string) string {
+	text = strings.TrimSpace(text)
+	if s.Links == LinkDrop {
+		return text
+	}
+	s.n++
+	s.pending = append(s.pending, link{url, stripEmphasis(strings.ReplaceAll(text, "\x00", "")), s.n})
+	if s.Links == LinkAfter {
+		return text
+	}
+	return fmt.Sprintf("%s[%d]", text, s.n)
+}
+
+// parseLink parses a [text](url), [text][ref], [text][] or [text] link at the start of src
+//
+// It returns the number of bytes consumed, or 0 if there is no link.
+func (s *state) parseLink(src string) (text, url string, n int) {
+	end := matchBracket(src, '[', ']')
+	if end < 0 {
+		return "", "", 0
+	}
+	label := src[1:end]
+	text = s.nested(label)
+	rest := src[end+1:]
+
+	switch {
+	case strings.HasPrefix(rest, "("):
+		close := matchBracket(rest, '(', ')')
+		if close < 0 {
+			break
+		}
+		dest := strings.TrimSpace(rest[1:close])
+		if strings.HasPrefix(dest, "<") {
+			if e := strings.IndexByte(dest, '>'); e > 0 {
+				dest = dest[1:e]
+			}
+		} else if f := strings.Fields(dest); len(f) > 0 {
+			dest = f[0] // drop the title
+		}
+		return text, dest, end + 1 + close + 1
+
+	case strings.HasPrefix(rest, "["):
+		close := strings.IndexByte(rest, ']')
+		if close < 0 {
+			break
+		}
+		ref := rest[1:close]
+		if ref == "" {
+			ref = label
+		}
+		if url, ok := s.refs[normalizeRef(ref)]; ok {
+			return text, url, end + 1 + close + 1
+		}
+		return "", "", 0
+	}
+
+	if url, ok := s.refs[normalizeRef(label)]; ok {
+		return text, url, end + 1
+	}
+	return "", "", 0
+}
+
+// nested reduces the label of a link, without queueing links of its own (links cannot nest)
+func (s *state) nested(label string) string {
+	sub := state{Converter: &Converter{Links: LinkDrop, KeepEmphasis: true}, refs: s.refs}
+	return sub.inline(label)
+}
+
+// returns the index of the bracket closing the one at src[0], honoring escapes and nesting
+func matchBracket(src string, open, close byte) int {
+	depth := 0
+	for i := 0; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func runLength(s string, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	return n
+}
+
+func isAutolink(s string) bool {
+	if strings.ContainsAny(s, " \t<") {
+		return false
+	}
+	if i := strings.IndexByte(s, ':'); i > 1 {
+		return true // scheme:...
+	}
+	return strings.Contains(s, "@") && !strings.HasPrefix(s, "@") // email
+}
+
+// a delimiter run that may open or close emphasis
+type delim struct {
+	pos, len int
+	char     byte
+	open     bool
+	close    bool
+}
+
+// stripEmphasis removes matched emphasis and strikethrough delimiter runs
+func stripEmphasis(s string) string {
+	var runs []delim
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c != '*' && c != '_' && c != '~' || i > 0 && s[i-1] == 0 { // escaped
+			i++
+			continue
+		}
+		n := runLength(s[i:], c)
+		if c == '~' && n != 2 {
+			i += n
+			continue
+		}
+		before, _ := utf8.DecodeLastRuneInString(s[:i])
+		after, _ := utf8.DecodeRuneInString(s[i+n:])
+		if i == 0 {
+			before = ' '
+		}
+		if i+n == len(s) {
+			after = ' '
+		}
+		left := !unicode.IsSpace(after) && (!isPunct(after) || unicode.IsSpace(before) || isPunct(before))
+		right := !unicode.IsSpace(before) && (!isPunct(before) || unicode.IsSpace(after) || isPunct(after))
+		d := delim{i, n, c, left, right}
+		if c == '_' { // no intraword emphasis with underscores
+			d.open = left && (!right || isPunct(before))
+			d.close = right && (!left || isPunct(after))
+		}
+		runs = append(runs, d)
+		i += n
+	}
+
+	remove := make([]bool, len(runs))
+	var stack []int
+	for i, d := range runs {
+		if d.close {
+			matched := false
+			for j := len(stack) - 1; j >= 0; j-- {
+				if runs[stack[j]].char == d.char {
+					remove[stack[j]], remove[i] = true, true
+					stack = stack[:j]
+					matched = true
+					break
+				}
+			}
+			if matched {
+				continue
+			}
+		}
+		if d.open {
+			stack = append(stack, i)
+		}
+	}
+
+	var b strings.Builder
+	last := 0
+	for i, d := range runs {
+		if remove[i] {
+			b.WriteString(s[last:d.pos])
+			last = d.pos + d.len
+		}
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+func isPunct(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
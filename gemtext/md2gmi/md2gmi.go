@@ -0,0 +1,501 @@
+// Package md2gmi converts Markdown documents to gemtext.
+//
+// It understands the CommonMark block structure (headings, paragraphs, fenced and indented code, block quotes, nested lists, thematic breaks, reference definitions) as well as pipe tables.
+// Gemtext has no inline markup, so inline elements are reduced to plain text according to the Converter options.
+package md2gmi
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"toast.cafe/x/gemini/gemtext"
+)
+
+// LinkMode controls how inline links are handled
+type LinkMode int
+
+// Link modes
+const (
+	LinkFootnote    LinkMode = iota // "text[1]" in the paragraph, "=> url [1] text" after it
+	LinkAfter                       // plain "text" in the paragraph, "=> url text" after it
+	LinkDocumentEnd                 // like LinkFootnote, but all links are gathered at the end of the document
+	LinkDrop                        // plain "text" in the paragraph, the url is discarded
+)
+
+// TableMode controls how tables are handled
+type TableMode int
+
+// Table modes
+const (
+	TablePreformatted TableMode = iota // an aligned preformatted block
+	TableList                          // one list item per row, cells separated by " | "
+	TableDrop                          // tables are discarded
+)
+
+// Converter converts Markdown to gemtext
+//
+// The zero value is ready to use and applies the defaults.
+type Converter struct {
+	Links  LinkMode
+	Tables TableMode
+
+	// NestedListPrefix is repeated once per nesting level in front of nested list items.
+	//
+	// Lists are always flattened, as gemtext has no nesting, an empty prefix makes all items look the same.
+	NestedListPrefix string
+
+	// KeepEmphasis keeps emphasis markers (*, _, ~~) instead of dropping them.
+	KeepEmphasis bool
+}
+
+// Convert converts src using the default options
+func Convert(src []byte) gemtext.Document {
+	var c Converter
+	return c.Convert(src)
+}
+
+// a link found in inline content
+type link struct {
+	url   string
+	label string
+	n     int // footnote number
+}
+
+// per-document conversion state
+type state struct {
+	*Converter
+	refs    map[string]string // reference definitions
+	doc     gemtext.Document
+	pending []link // links waiting to be emitted
+	n       int    // last footnote number
+}
+
+var (
+	reATX      = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	reSetext   = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	reFence    = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*(.*)$")
+	reBreak    = regexp.MustCompile(`^ {0,3}((\*[ \t]*){3,}|(-[ \t]*){3,}|(_[ \t]*){3,})$`)
+	reItem     = regexp.MustCompile(`^( *)([-*+]|[0-9]{1,9}[.)])(?:[ \t]+(.*))?$`)
+	reRef      = regexp.MustCompile(`^ {0,3}\[([^\]]+)\]:[ \t]*<?([^ \t>]+)>?(?:[ \t]+(?:"[^"]*"|'[^']*'|\([^)]*\)))?[ \t]*$`)
+	reTableSep = regexp.MustCompile(`^ {0,3}\|?[ \t]*:?-+:?[ \t]*(\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+)
+
+// Convert converts src to a gemtext document
+func (c *Converter) Convert(src []byte) gemtext.Document {
+	src = bytes.ReplaceAll(src, []byte("\r\n"), []byte("\n"))
+	lines := strings.Split(string(src), "\n")
+
+	s := state{Converter: c, refs: make(map[string]string)}
+	lines = s.collectRefs(lines)
+	s.blocks(lines)
+	if c.Links == LinkDocumentEnd && len(s.pending) > 0 {
+		s.sep()
+		s.flushLinks()
+	}
+	return s.doc
+}
+
+// collectRefs records and removes reference definitions outside of code blocks
+func (s *state) collectRefs(lines []string) []string {
+	out := lines[:0:0]
+	fence := ""
+	for _, l := range lines {
+		if m := reFence.FindStringSubmatch(l); m != nil {
+			switch {
+			case fence == "":
+				fence = m[2]
+			case strings.HasPrefix(m[2], fence[:1]) && len(m[2]) >= len(fence) && m[3] == "":
+				fence = ""
+			}
+		} else if fence == "" {
+			if m := reRef.FindStringSubmatch(l); m != nil {
+				key := normalizeRef(m[1])
+				if _, ok := s.refs[key]; !ok { // first definition wins
+					s.refs[key] = m[2]
+				}
+				continue
+			}
+		}
+		out = append(out, l)
+	}
+	return out
+}
+
+func normalizeRef(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+func (s *state) emit(l gemtext.Line) {
+	s.doc = append(s.doc, l)
+}
+
+// separates blocks with a blank line
+func (s *state) sep() {
+	if len(s.doc) > 0 && s.doc[len(s.doc)-1] != gemtext.Text("") {
+		s.emit(gemtext.Text(""))
+	}
+}
+
+// emits pending links, unless they are meant for the end of the document
+func (s *state) paragraphEnd() {
+	if s.Links != LinkDocumentEnd {
+		s.flushLinks()
+	}
+}
+
+func (s *state) flushLinks() {
+	for _, v := range s.pending {
+		label := v.label
+		if s.Links != LinkAfter {
+			label = strings.TrimSpace(fmt.Sprintf("[%d] %s", v.n, label))
+		}
+		s.emit(&gemtext.Link{URL: v.url, Label: label})
+	}
+	s.pending = nil
+}
+
+// blocks converts a sequence of lines at the top level (or within a block quote)
+func (s *state) blocks(lines []string) {
+	var para []string
+	endPara := func() {
+		if len(para) == 0 {
+			return
+		}
+		s.sep()
+		for _, v := range s.paragraph(para) {
+			s.emit(gemtext.Text(v))
+		}
+		para = nil
+		s.paragraphEnd()
+	}
+
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		switch {
+		case strings.TrimSpace(l) == "":
+			endPara()
+
+		case len(para) > 0 && reSetext.MatchString(l):
+			level := 1
+			if strings.TrimSpace(l)[0] == '-' {
+				level = 2
+			}
+			text := s.inline(strings.Join(trimAll(para), " "))
+			para = nil
+			s.sep()
+			s.emit(&gemtext.Heading{Level: level, Text: text})
+			s.paragraphEnd()
+
+		case reBreak.MatchString(l):
+			endPara()
+
+		case reATX.MatchString(l):
+			endPara()
+			m := reATX.FindStringSubmatch(l)
+			level := len(m[1])
+			if level > 3 {
+				level = 3
+			}
+			s.sep()
+			s.emit(&gemtext.Heading{Level: level, Text: s.inline(m[2])})
+			s.paragraphEnd()
+
+		case reFence.MatchString(l):
+			endPara()
+			m := reFence.FindStringSubmatch(l)
+			indent, fence := len(m[1]), m[2]
+			alt := m[3]
+			if fence[0] == '`' && strings.Contains(alt, "`") { // not a fence after all
+				para = append(para, l)
+				continue
+			}
+			s.sep()
+			block := &gemtext.Preformatted{Alt: strings.TrimSpace(alt)}
+			for i++; i < len(lines); i++ {
+				if m := reFence.FindStringSubmatch(lines[i]); m != nil && m[2][0] == fence[0] && len(m[2]) >= len(fence) && strings.TrimSpace(m[3]) == "" {
+					break
+				}
+				block.Lines = append(block.Lines, trimIndent(lines[i], indent))
+			}
+			s.emit(block)
+
+		case len(para) == 0 && strings.HasPrefix(expandTabs(l), "    "):
+			s.sep()
+			block := new(gemtext.Preformatted)
+			for ; i < len(lines); i++ {
+				e := expandTabs(lines[i])
+				if strings.TrimSpace(e) != "" && !strings.HasPrefix(e, "    ") {
+					break
+				}
+				block.Lines = append(block.Lines, trimIndent(e, 4))
+			}
+			i--
+			for len(block.Lines) > 0 && strings.TrimSpace(block.Lines[len(block.Lines)-1]) == "" {
+				block.Lines = block.Lines[:len(block.Lines)-1]
+			}
+			s.emit(block)
+
+		case strings.HasPrefix(strings.TrimLeft(l, " "), ">"):
+			endPara()
+			var quoted []string
+			for ; i < len(lines); i++ {
+				t := strings.TrimLeft(lines[i], " ")
+				if !strings.HasPrefix(t, ">") {
+					if strings.TrimSpace(t) == "" || len(quoted) == 0 {
+						break
+					}
+					quoted = append(quoted, t) // lazy continuation
+					continue
+				}
+				t = strings.TrimPrefix(t[1:], " ")
+				quoted = append(quoted, t)
+			}
+			i--
+			s.quote(quoted)
+
+		case reItem.MatchString(l) && (len(para) == 0 || reItem.FindStringSubmatch(l)[3] != ""):
+			endPara()
+			i = s.list(lines, i) - 1
+
+		case i+1 < len(lines) && strings.Contains(l, "|") && reTableSep.MatchString(lines[i+1]):
+			endPara()
+			i = s.table(lines, i) - 1
+
+		default:
+			para = append(para, l)
+		}
+	}
+	endPara()
+}
+
+// quote converts the contents of a block quote, every paragraph becomes one quote line
+func (s *state) quote(lines []string) {
+	var para []string
+	s.sep()
+	end := func() {
+		if len(para) > 0 {
+			for _, v := range s.paragraph(para) {
+				s.emit(gemtext.Quote(v))
+			}
+			para = nil
+			s.paragraphEnd()
+		}
+	}
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			end()
+			continue
+		}
+		para = append(para, strings.TrimLeft(l, "> "))
+	}
+	end()
+}
+
+// list converts a (possibly nested) list starting at lines[i], returning the index after it
+func (s *state) list(lines []string, i int) int {
+	var indents []int // indentation of each open nesting level
+	var item []string // lines of the current item
+	var prefix string // prefix of the current item
+	ordered := false
+	s.sep()
+
+	end := func() {
+		if len(item) == 0 {
+			return
+		}
+		text := s.inline(strings.Join(trimAll(item), " "))
+		if ordered {
+			text = prefix + " " + text
+		}
+		depth := len(indents) - 1
+		s.emit(gemtext.ListItem(strings.Repeat(s.NestedListPrefix, depth) + text))
+		item = nil
+		s.paragraphEnd()
+	}
+
+	for ; i < len(lines); i++ {
+		l := expandTabs(lines[i])
+		if strings.TrimSpace(l) == "" {
+			// a blank line ends the list unless the next line continues it
+			if i+1 < len(lines) && (reItem.MatchString(lines[i+1]) || strings.HasPrefix(expandTabs(lines[i+1]), "  ")) {
+				continue
+			}
+			break
+		}
+		if m := reItem.FindStringSubmatch(l); m != nil && !reBreak.MatchString(l) {
+			end()
+			indent := len(m[1])
+			for len(indents) > 0 && indent < indents[len(indents)-1] {
+				indents = indents[:len(indents)-1]
+			}
+			if len(indents) == 0 || indent > indents[len(indents)-1] {
+				indents = append(indents, indent)
+			}
+			prefix = m[2]
+			ordered = prefix[0] >= '0' && prefix[0] <= '9'
+			item = append(item, m[3])
+			continue
+		}
+		if len(item) == 0 || reATX.MatchString(l) || reFence.MatchString(l) || strings.HasPrefix(strings.TrimLeft(l, " "), ">") {
+			break
+		}
+		item = append(item, l) // continuation
+	}
+	end()
+	return i
+}
+
+// table converts a pipe table starting at lines[i], returning the index after it
+func (s *state) table(lines []string, i int) int {
+	var rows [][]string
+	header := i
+	pending, n := len(s.pending), s.n // so that dropping the table only drops its own links
+	for ; i < len(lines); i++ {
+		if i == header+1 { // delimiter row
+			continue
+		}
+		l := lines[i]
+		if strings.TrimSpace(l) == "" || !strings.Contains(l, "|") {
+			break
+		}
+		cells := splitRow(l)
+		for j := range cells {
+			cells[j] = s.inline(cells[j])
+		}
+		rows = append(rows, cells)
+	}
+
+	if s.Tables != TableDrop {
+		s.sep()
+	}
+	switch s.Tables {
+	case TableDrop:
+		s.pending, s.n = s.pending[:pending], n
+		return i
+	case TableList:
+		for _, r := range rows {
+			s.emit(gemtext.ListItem(strings.Join(r, " | ")))
+		}
+		s.paragraphEnd()
+		return i
+	}
+
+	var widths []int
+	for _, r := range rows {
+		for j, c := range r {
+			if j >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if w := utf8.RuneCountInString(c); w > widths[j] {
+				widths[j] = w
+			}
+		}
+	}
+	block := &gemtext.Preformatted{Alt: "table"}
+	for n, r := range rows {
+		var b strings.Builder
+		for j, c := range r {
+			if j > 0 {
+				b.WriteString(" | ")
+			}
+			b.WriteString(c)
+			if j < len(r)-1 {
+				b.WriteString(strings.Repeat(" ", widths[j]-utf8.RuneCountInString(c)))
+			}
+		}
+		block.Lines = append(block.Lines, b.String())
+		if n == 0 {
+			var sep []string
+			for _, w := range widths {
+				sep = append(sep, strings.Repeat("-", w))
+			}
+			block.Lines = append(block.Lines, strings.Join(sep, "-+-"))
+		}
+	}
+	s.emit(block)
+	s.paragraphEnd()
+	return i
+}
+
+func splitRow(l string) []string {
+	l = strings.TrimSpace(l)
+	l = strings.TrimPrefix(l, "|")
+	if !strings.HasSuffix(l, `\|`) {
+		l = strings.TrimSuffix(l, "|")
+	}
+	var cells []string
+	var b strings.Builder
+	for i := 0; i < len(l); i++ {
+		switch {
+		case l[i] == '\\' && i+1 < len(l) && l[i+1] == '|':
+			b.WriteByte('|')
+			i++
+		case l[i] == '|':
+			cells = append(cells, strings.TrimSpace(b.String()))
+			b.Reset()
+		default:
+			b.WriteByte(l[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(b.String()))
+}
+
+// paragraph converts the lines of a paragraph, returning one string per hard line break
+func (s *state) paragraph(lines []string) []string {
+	var out []string
+	var cur []string
+	for _, l := range lines {
+		hard := strings.HasSuffix(l, "  ") || strings.HasSuffix(l, `\`)
+		l = strings.TrimSpace(l)
+		if hard {
+			l = strings.TrimSuffix(l, `\`)
+		}
+		cur = append(cur, l)
+		if hard {
+			out = append(out, s.inline(strings.Join(cur, " ")))
+			cur = nil
+		}
+	}
+	if len(cur) > 0 {
+		out = append(out, s.inline(strings.Join(cur, " ")))
+	}
+	return out
+}
+
+func trimAll(lines []string) []string {
+	out := make([]string, len(lines))
+	for i, v := range lines {
+		out[i] = strings.TrimSpace(v)
+	}
+	return out
+}
+
+// removes up to n leading spaces
+func trimIndent(s string, n int) string {
+	for i := 0; i < n && strings.HasPrefix(s, " "); i++ {
+		s = s[1:]
+	}
+	return s
+}
+
+func expandTabs(s string) string {
+	if !strings.Contains(s, "\t") {
+		return s
+	}
+	var b strings.Builder
+	col := 0
+	for _, r := range s {
+		if r == '\t' {
+			n := 4 - col%4
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(r)
+		col++
+	}
+	return b.String()
+}
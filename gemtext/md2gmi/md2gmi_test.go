@@ -0,0 +1,103 @@
+package md2gmi_test
+
+import (
+	"testing"
+
+	"toast.cafe/x/gemini/gemtext/md2gmi"
+)
+
+var testmd = "Title\n" +
+	"=====\n" +
+	"\n" +
+	"Some *emph* and __strong__ text with a [link](http://x.org \"title\") and snake_case and \\*lit\\*.\n" +
+	"Hard break  \n" +
+	"![img](i.png) <https://auto.link> [ref][r]\n" +
+	"\n" +
+	"[r]: gemini://ref.example\n" +
+	"\n" +
+	"#### Deep ###\n" +
+	"\n" +
+	"- one\n" +
+	"  continued\n" +
+	"  - nested `co*de*`\n" +
+	"- two\n" +
+	"\n" +
+	"1. first\n" +
+	"\n" +
+	"> quoted *text*\n" +
+	"> more\n" +
+	"\n" +
+	"| a | b |\n" +
+	"|---|:-:|\n" +
+	"| long cell | [x](y) |\n" +
+	"\n" +
+	"```go\n" +
+	"*code*\n" +
+	"```\n" +
+	"\n" +
+	"---\n" +
+	"~~gone~~ 2 * 3\n"
+
+func TestConvert(t *testing.T) {
+	expect := "# Title\n" +
+		"\n" +
+		"Some emph and strong text with a link[1] and snake_case and *lit*. Hard break\n" +
+		"img[2] https://auto.link[3] ref[4]\n" +
+		"=> http://x.org [1] link\n" +
+		"=> i.png [2] img\n" +
+		"=> https://auto.link [3] https://auto.link\n" +
+		"=> gemini://ref.example [4] ref\n" +
+		"\n" +
+		"### Deep\n" +
+		"\n" +
+		"* one continued\n" +
+		"* nested `co*de*`\n" +
+		"* two\n" +
+		"* 1. first\n" +
+		"\n" +
+		"> quoted text more\n" +
+		"\n" +
+		"```table\n" +
+		"a         | b\n" +
+		"----------+-----\n" +
+		"long cell | x[5]\n" +
+		"```\n" +
+		"=> y [5] x\n" +
+		"\n" +
+		"```go\n" +
+		"*code*\n" +
+		"```\n" +
+		"\n" +
+		"gone 2 * 3\n"
+
+	out := md2gmi.Convert([]byte(testmd)).String()
+	if out != expect {
+		t.Errorf("expected %q, instead found %q", expect, out)
+	}
+}
+
+func TestDropTableKeepsLinks(t *testing.T) {
+	c := md2gmi.Converter{Links: md2gmi.LinkDocumentEnd, Tables: md2gmi.TableDrop}
+	out := c.Convert([]byte("see [a](x)\n\n| [t](z) |\n|---|\n| v |\n\nand [b](y)\n")).String()
+	if expect := "see a[1]\n\nand b[2]\n\n=> x [1] a\n=> y [2] b\n"; out != expect {
+		t.Errorf("expected %q, instead found %q", expect, out)
+	}
+}
+
+func TestConvertOptions(t *testing.T) {
+	src := []byte("- [a](x) *b*\n  - c\n\n| h |\n|---|\n| v |\n")
+	for _, v := range []struct {
+		c      md2gmi.Converter
+		expect string
+	}{
+		{md2gmi.Converter{Links: md2gmi.LinkAfter}, "* a b\n=> x a\n* c\n\n```table\nh\n-\nv\n```\n"},
+		{md2gmi.Converter{Links: md2gmi.LinkDrop, Tables: md2gmi.TableDrop}, "* a b\n* c\n"},
+		{md2gmi.Converter{Links: md2gmi.LinkDocumentEnd, Tables: md2gmi.TableList}, "* a[1] b\n* c\n\n* h\n* v\n\n=> x [1] a\n"},
+		{md2gmi.Converter{Links: md2gmi.LinkDrop, NestedListPrefix: "- ", KeepEmphasis: true}, "* a *b*\n* - c\n\n```table\nh\n-\nv\n```\n"},
+	} {
+		out := v.c.Convert(src).String()
+		if out != v.expect {
+			t.Errorf("%+v: expected %q, instead found %q", v.c, v.expect, out)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package gemtext
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// whitespace as defined by the spec
+const whitespace = " \t"
+
+// Scanner walks a gemtext document one line at a time
+//
+// It does not hold onto the document, making it suitable for large or streamed bodies.
+// Preformatted blocks are reported as a PreformatToggle, zero or more PreformattedText lines, and another PreformatToggle.
+// The closing PreformatToggle may be missing if the document ends in the middle of a block.
+type Scanner struct {
+	r    *bufio.Reader
+	line Line
+	err  error
+	pre  bool // are we in a preformatted block?
+}
+
+// NewScanner returns a Scanner reading from r
+//
+// Note that *gemini.Response is a valid io.Reader.
+func NewScanner(r io.Reader) *Scanner {
+	return &Scanner{r: bufio.NewReader(r)}
+}
+
+// Scan advances to the next line, which is then available through Line
+//
+// It returns false when there are no more lines, either due to an error or the end of the input.
+func (s *Scanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	str, err := s.r.ReadString('\n')
+	if err != nil {
+		s.err = err
+		if str == "" { // io.Reader says we should process data before looking at errors
+			s.line = nil
+			return false
+		}
+	}
+	str = strings.TrimSuffix(str, "\n")
+	str = strings.TrimSuffix(str, "\r")
+
+	if strings.HasPrefix(str, "```") {
+		s.pre = !s.pre
+		s.line = &PreformatToggle{strings.TrimLeft(str[3:], whitespace)}
+	} else if s.pre {
+		s.line = PreformattedText(str)
+	} else {
+		s.line = ParseLine(str)
+	}
+	return true
+}
+
+// Line returns the most recent line found by Scan
+func (s *Scanner) Line() Line {
+	return s.line
+}
+
+// Preformatted returns true if the scanner is currently within a preformatted block
+func (s *Scanner) Preformatted() bool {
+	return s.pre
+}
+
+// Err returns the first non-EOF error encountered by the Scanner
+func (s *Scanner) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
+
+// ParseLine parses a single line outside of a preformatted block
+//
+// The line should not include the trailing newline.
+// Preformatting toggles are returned as a *PreformatToggle, it is up to the caller to track the state.
+func ParseLine(s string) Line {
+	switch {
+	case strings.HasPrefix(s, "```"):
+		return &PreformatToggle{strings.TrimLeft(s[3:], whitespace)}
+	case strings.HasPrefix(s, "=>"):
+		rest := strings.TrimLeft(s[2:], whitespace)
+		if rest == "" { // a link without a url is not a link
+			return Text(s)
+		}
+		var l Link
+		if i := strings.IndexAny(rest, whitespace); i >= 0 {
+			l.URL = rest[:i]
+			l.Label = strings.TrimLeft(rest[i:], whitespace)
+		} else {
+			l.URL = rest
+		}
+		return &l
+	case strings.HasPrefix(s, "###"):
+		return &Heading{3, strings.TrimLeft(s[3:], whitespace)}
+	case strings.HasPrefix(s, "##"):
+		return &Heading{2, strings.TrimLeft(s[2:], whitespace)}
+	case strings.HasPrefix(s, "#"):
+		return &Heading{1, strings.TrimLeft(s[1:], whitespace)}
+	case strings.HasPrefix(s, "* "):
+		return ListItem(s[2:])
+	case strings.HasPrefix(s, ">"):
+		return Quote(strings.TrimLeft(s[1:], whitespace))
+	}
+	return Text(s)
+}
+
+// Parse reads an entire gemtext document from r
+//
+// Unlike the Scanner, preformatted blocks are grouped into a single *Preformatted line.
+// A block left open at the end of the document is closed implicitly.
+func Parse(r io.Reader) (Document, error) {
+	var doc Document
+	var block *Preformatted
+
+	s := NewScanner(r)
+	for s.Scan() {
+		switch v := s.Line().(type) {
+		case *PreformatToggle:
+			if block == nil {
+				block = &Preformatted{Alt: v.Alt}
+				continue
+			}
+			doc = append(doc, block)
+			block = nil
+		case PreformattedText:
+			block.Lines = append(block.Lines, string(v))
+		default:
+			doc = append(doc, v)
+		}
+	}
+	if block != nil {
+		doc = append(doc, block)
+	}
+	return doc, s.Err()
+}
+
+// ParseString parses a gemtext document from a string
+func ParseString(s string) Document {
+	doc, _ := Parse(strings.NewReader(s)) // strings.Reader never errors
+	return doc
+}
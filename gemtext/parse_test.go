@@ -0,0 +1,90 @@
+package gemtext_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/gemtext"
+)
+
+var testdoc = "# Title\r\n" +
+	"some text\n" +
+	"=> gemini://example.org/ Example\n" +
+	"=>\t/relative\n" +
+	"=>\n" +
+	"## Sub\n" +
+	"###  Subsub\n" +
+	"* item\n" +
+	"*not an item\n" +
+	">quoted\n" +
+	"```alt text\n" +
+	"# not a heading\n" +
+	"```\n" +
+	"```\n" +
+	"unterminated"
+
+var testparsed = gemtext.Document{
+	&gemtext.Heading{Level: 1, Text: "Title"},
+	gemtext.Text("some text"),
+	&gemtext.Link{URL: "gemini://example.org/", Label: "Example"},
+	&gemtext.Link{URL: "/relative"},
+	gemtext.Text("=>"),
+	&gemtext.Heading{Level: 2, Text: "Sub"},
+	&gemtext.Heading{Level: 3, Text: "Subsub"},
+	gemtext.ListItem("item"),
+	gemtext.Text("*not an item"),
+	gemtext.Quote("quoted"),
+	&gemtext.Preformatted{Alt: "alt text", Lines: []string{"# not a heading"}},
+	&gemtext.Preformatted{Lines: []string{"unterminated"}},
+}
+
+func TestParse(t *testing.T) {
+	doc, err := gemtext.Parse(strings.NewReader(testdoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(doc, testparsed) {
+		t.Errorf("expected %#v, instead found %#v", testparsed, doc)
+	}
+}
+
+func TestScanner(t *testing.T) {
+	s := gemtext.NewScanner(strings.NewReader("```go\nfunc\n```\n=> x"))
+	var lines []gemtext.Line
+	for s.Scan() {
+		lines = append(lines, s.Line())
+	}
+	expect := []gemtext.Line{
+		&gemtext.PreformatToggle{Alt: "go"},
+		gemtext.PreformattedText("func"),
+		&gemtext.PreformatToggle{},
+		&gemtext.Link{URL: "x"},
+	}
+	if !reflect.DeepEqual(lines, expect) {
+		t.Errorf("expected %#v, instead found %#v", expect, lines)
+	}
+}
+
+// parsing what we print should be lossless
+func TestRoundTrip(t *testing.T) {
+	doc := gemtext.ParseString(testparsed.String())
+	if !reflect.DeepEqual(doc, testparsed) {
+		t.Errorf("expected %#v, instead found %#v", testparsed, doc)
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	var r gemini.Response
+	if err := r.FromReader(strings.NewReader("20 text/gemini\r\n# hi\n")); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := gemtext.Parse(&r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Title() != "hi" {
+		t.Errorf("expected title %q, instead found %q", "hi", doc.Title())
+	}
+}
@@ -0,0 +1,187 @@
+package gemtext
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// ansi escape sequences used by TermRenderer
+const (
+	ansiReset  = "\x1b[0m"
+	ansiFaint  = "\x1b[2m"
+	ansiItalic = "\x1b[3m"
+	ansiH1     = "\x1b[1;35m"
+	ansiH2     = "\x1b[1;36m"
+	ansiH3     = "\x1b[1;34m"
+	ansiLink   = "\x1b[1;33m"
+)
+
+// DefaultTermWidth is the width used by a TermRenderer with no Width
+const DefaultTermWidth = 80
+
+// TermRenderer renders gemtext documents for display on a terminal
+//
+// Text is word-wrapped to Width, with hanging indents for list items, quotes and links.
+// Links are numbered in order, starting at 1, so that users can pick one.
+// Preformatted blocks are passed through as-is, without wrapping.
+// Control characters other than tabs are stripped from the document, so that it can't send its own escape sequences.
+type TermRenderer struct {
+	// Width is the terminal width to wrap at, DefaultTermWidth if zero.
+	Width int
+
+	// NoColor disables all ansi escape sequences, headings keep their # markers instead.
+	NoColor bool
+
+	// ShowURLs displays the url of labelled links after the label.
+	ShowURLs bool
+}
+
+// Render renders the document to w
+//
+// It returns the numbered link table, link n is at index n-1.
+func (t *TermRenderer) Render(w io.Writer, d Document) ([]*Link, error) {
+	bw := bufio.NewWriter(w)
+	var links []*Link
+
+	for _, l := range d {
+		switch v := l.(type) {
+		case Text:
+			t.wrap(bw, "", "", "", string(v))
+		case *Link:
+			links = append(links, v)
+			num := fmt.Sprintf("[%d] ", len(links))
+			text := v.Label
+			if text == "" {
+				text = v.URL
+			} else if t.ShowURLs {
+				text += " (" + v.URL + ")"
+			}
+			t.wrap(bw, t.style(ansiLink, num), strings.Repeat(" ", len(num)), "", text)
+		case *Heading:
+			style := ansiH1
+			switch v.Level {
+			case 2:
+				style = ansiH2
+			case 3:
+				style = ansiH3
+			}
+			if t.NoColor { // keep the markers, or headings would read as text
+				mark := strings.Repeat("#", v.Level) + " "
+				t.wrap(bw, mark, strings.Repeat(" ", len(mark)), "", v.Text)
+				break
+			}
+			t.wrap(bw, "", "", style, v.Text)
+		case ListItem:
+			t.wrap(bw, "• ", "  ", "", string(v))
+		case Quote:
+			bar := t.style(ansiFaint, "> ")
+			t.wrap(bw, bar, bar, ansiItalic, string(v))
+		case *Preformatted:
+			for _, s := range v.Lines {
+				bw.WriteString(stripControl(s))
+				bw.WriteByte('\n')
+			}
+		case PreformattedText:
+			bw.WriteString(stripControl(string(v)))
+			bw.WriteByte('\n')
+		}
+	}
+	return links, bw.Flush()
+}
+
+// RenderReader renders a gemtext document straight from a reader, such as a *gemini.Response
+func (t *TermRenderer) RenderReader(w io.Writer, r io.Reader) ([]*Link, error) {
+	d, err := Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return t.Render(w, d)
+}
+
+// style wraps s in the given style, unless colors are disabled
+func (t *TermRenderer) style(style, s string) string {
+	if t.NoColor || style == "" {
+		return s
+	}
+	return style + s + ansiReset
+}
+
+// wrap writes text word-wrapped to the width, first is prefixed to the first line and rest to the others
+func (t *TermRenderer) wrap(w *bufio.Writer, first, rest, style, text string) {
+	width := t.Width
+	if width <= 0 {
+		width = DefaultTermWidth
+	}
+	indent := utf8.RuneCountInString(stripANSI(rest))
+	avail := width - indent
+	if avail < 1 {
+		avail = 1
+	}
+
+	lines := wrapText(stripControl(text), avail)
+	for i, v := range lines {
+		if i == 0 {
+			w.WriteString(first)
+		} else {
+			w.WriteString(rest)
+		}
+		w.WriteString(t.style(style, v))
+		w.WriteByte('\n')
+	}
+}
+
+// wrapText splits text into lines of at most width runes, breaking on spaces where possible
+func wrapText(text string, width int) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return []string{""}
+	}
+
+	var lines []string
+	var cur []rune
+	for _, word := range words {
+		wr := []rune(word)
+		if len(cur) > 0 && len(cur)+1+len(wr) > width {
+			lines = append(lines, string(cur))
+			cur = cur[:0]
+		}
+		if len(cur) > 0 {
+			cur = append(cur, ' ')
+		}
+		for len(cur)+len(wr) > width { // the word alone doesn't fit, hard break it
+			n := width - len(cur)
+			lines = append(lines, string(append(cur, wr[:n]...)))
+			cur = cur[:0]
+			wr = wr[n:]
+		}
+		cur = append(cur, wr...)
+	}
+	return append(lines, string(cur))
+}
+
+// stripControl removes C0 and C1 control characters, except for tabs
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r != '\t' && (r < 0x20 || r >= 0x7f && r < 0xa0) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func stripANSI(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\x1b' {
+			for i < len(s) && s[i] != 'm' {
+				i++
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
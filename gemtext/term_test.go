@@ -0,0 +1,78 @@
+package gemtext_test
+
+import (
+	"strings"
+	"testing"
+
+	"toast.cafe/x/gemini/gemtext"
+)
+
+func TestRenderTerm(t *testing.T) {
+	doc := gemtext.ParseString("# Heading\n" +
+		"## Sub heading wraps\n" +
+		"some text that is long enough to wrap\n" +
+		"* a list item that wraps too\n" +
+		"> a quote that wraps\n" +
+		"=> gemini://example.org/ a link that wraps\n" +
+		"=> /bare\n" +
+		"```\n" +
+		"preformatted text is never wrapped\n" +
+		"```\n")
+	r := gemtext.TermRenderer{Width: 16, NoColor: true}
+
+	var b strings.Builder
+	links, err := r.Render(&b, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "# Heading\n" +
+		"## Sub heading\n" +
+		"   wraps\n" +
+		"some text that\n" +
+		"is long enough\n" +
+		"to wrap\n" +
+		"• a list item\n" +
+		"  that wraps too\n" +
+		"> a quote that\n" +
+		"> wraps\n" +
+		"[1] a link that\n" +
+		"    wraps\n" +
+		"[2] /bare\n" +
+		"preformatted text is never wrapped\n"
+	if b.String() != expect {
+		t.Errorf("expected %q, instead found %q", expect, b.String())
+	}
+	if len(links) != 2 || links[1].URL != "/bare" {
+		t.Errorf("unexpected link table %v", links)
+	}
+}
+
+func TestRenderTermColor(t *testing.T) {
+	r := gemtext.TermRenderer{Width: 4}
+	var b strings.Builder
+	r.Render(&b, gemtext.Document{&gemtext.Heading{Level: 1, Text: "abcdefgh"}})
+	expect := "\x1b[1;35mabcd\x1b[0m\n\x1b[1;35mefgh\x1b[0m\n"
+	if b.String() != expect {
+		t.Errorf("expected %q, instead found %q", expect, b.String())
+	}
+}
+
+func TestRenderTermControl(t *testing.T) {
+	doc := gemtext.ParseString("evil\x1b]0;title\x07 text\n" +
+		"=> /x a\u009b31m label\n" +
+		"```\n" +
+		"a\tb\x1b[2J\r\n" +
+		"```\n")
+	r := gemtext.TermRenderer{NoColor: true}
+
+	var b strings.Builder
+	if _, err := r.Render(&b, doc); err != nil {
+		t.Fatal(err)
+	}
+	expect := "evil]0;title text\n" +
+		"[1] a31m label\n" +
+		"a\tb[2J\n"
+	if b.String() != expect {
+		t.Errorf("expected %q, instead found %q", expect, b.String())
+	}
+}
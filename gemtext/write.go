@@ -0,0 +1,229 @@
+package gemtext
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+type gemtextError string
+
+func (e gemtextError) Error() string { return string(e) }
+
+// Gemtext errors
+const (
+	ErrHeading = gemtextError("invalid heading level")
+	ErrLink    = gemtextError("invalid link")
+)
+
+// replaces characters that would break out of a single line
+var lineEscaper = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+// replaces characters that would break a link url
+var urlEscaper = strings.NewReplacer(" ", "%20", "\t", "%09", "\r", "%0D", "\n", "%0A")
+
+// Writer writes well-formed gemtext to an underlying writer
+//
+// Note that *gemini.Response is a valid io.Writer.
+// Input that would break the line syntax is escaped: newlines in single-line elements are replaced by spaces,
+// whitespace in link urls is percent-encoded, and text or preformatted lines that would be mistaken for another line type are prefixed with a space.
+// Writing a non-preformatted line while a preformatted block is open closes the block first.
+//
+// The first error encountered is sticky, and is returned by all subsequent calls.
+// Call Close when done to make sure the final preformatted block is closed.
+type Writer struct {
+	w   io.Writer
+	pre bool // are we in a preformatted block?
+	err error
+}
+
+// NewWriter returns a Writer writing to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (w *Writer) writeLine(s string) error {
+	if w.err != nil {
+		return w.err
+	}
+	_, w.err = io.WriteString(w.w, s+"\n")
+	return w.err
+}
+
+// ensures we're out of a preformatted block
+func (w *Writer) unpre() error {
+	if w.pre {
+		return w.EndPreformatted()
+	}
+	return w.err
+}
+
+// Text writes one or more text lines
+//
+// Every line in s is written as its own text line.
+func (w *Writer) Text(s string) error {
+	if err := w.unpre(); err != nil {
+		return err
+	}
+	for _, v := range splitLines(s) {
+		if _, ok := ParseLine(v).(Text); !ok {
+			v = " " + v
+		}
+		if err := w.writeLine(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Heading writes a heading of the given level, which must be 1, 2 or 3
+func (w *Writer) Heading(level int, s string) error {
+	if level < 1 || level > 3 {
+		return fmt.Errorf("%w: %d", ErrHeading, level)
+	}
+	if err := w.unpre(); err != nil {
+		return err
+	}
+	return w.writeLine((&Heading{level, lineEscaper.Replace(s)}).String())
+}
+
+// Link writes a link line, the label is optional
+func (w *Writer) Link(url, label string) error {
+	url = strings.TrimSpace(url)
+	if url == "" {
+		return fmt.Errorf("%w: empty url", ErrLink)
+	}
+	if err := w.unpre(); err != nil {
+		return err
+	}
+	return w.writeLine((&Link{urlEscaper.Replace(url), lineEscaper.Replace(label)}).String())
+}
+
+// ListItem writes an unordered list item
+func (w *Writer) ListItem(s string) error {
+	if err := w.unpre(); err != nil {
+		return err
+	}
+	return w.writeLine(ListItem(lineEscaper.Replace(s)).String())
+}
+
+// Quote writes one or more quote lines
+//
+// Every line in s is written as its own quote line.
+func (w *Writer) Quote(s string) error {
+	if err := w.unpre(); err != nil {
+		return err
+	}
+	for _, v := range splitLines(s) {
+		if err := w.writeLine(Quote(v).String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartPreformatted opens a preformatted block with the given alt text
+//
+// If a block is already open, it is closed first.
+func (w *Writer) StartPreformatted(alt string) error {
+	if err := w.unpre(); err != nil {
+		return err
+	}
+	w.pre = true
+	return w.writeLine((&PreformatToggle{lineEscaper.Replace(alt)}).String())
+}
+
+// PreformattedText writes lines into the current preformatted block, opening one if needed
+func (w *Writer) PreformattedText(s string) error {
+	if !w.pre {
+		if err := w.StartPreformatted(""); err != nil {
+			return err
+		}
+	}
+	for _, v := range splitLines(s) {
+		if strings.HasPrefix(v, "```") {
+			v = " " + v
+		}
+		if err := w.writeLine(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EndPreformatted closes the current preformatted block, if any
+func (w *Writer) EndPreformatted() error {
+	if !w.pre {
+		return w.err
+	}
+	w.pre = false
+	return w.writeLine("```")
+}
+
+// Preformatted writes a complete preformatted block
+func (w *Writer) Preformatted(alt, body string) error {
+	if err := w.StartPreformatted(alt); err != nil {
+		return err
+	}
+	if err := w.PreformattedText(body); err != nil {
+		return err
+	}
+	return w.EndPreformatted()
+}
+
+// Line writes a typed line
+func (w *Writer) Line(l Line) error {
+	switch v := l.(type) {
+	case Text:
+		return w.Text(string(v))
+	case *Link:
+		return w.Link(v.URL, v.Label)
+	case *Heading:
+		return w.Heading(v.Level, v.Text)
+	case ListItem:
+		return w.ListItem(string(v))
+	case Quote:
+		return w.Quote(string(v))
+	case *PreformatToggle:
+		if w.pre {
+			return w.EndPreformatted()
+		}
+		return w.StartPreformatted(v.Alt)
+	case PreformattedText:
+		return w.PreformattedText(string(v))
+	case *Preformatted:
+		if err := w.StartPreformatted(v.Alt); err != nil {
+			return err
+		}
+		for _, s := range v.Lines {
+			if err := w.PreformattedText(s); err != nil {
+				return err
+			}
+		}
+		return w.EndPreformatted()
+	}
+	return w.err
+}
+
+// Document writes every line of a document
+func (w *Writer) Document(d Document) error {
+	for _, v := range d {
+		if err := w.Line(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes any open preformatted block
+//
+// It does not close the underlying writer.
+func (w *Writer) Close() error {
+	return w.EndPreformatted()
+}
+
+// splits s into lines, accepting both \n and \r\n
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,69 @@
+package gemtext_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/gemtext"
+)
+
+func TestWriter(t *testing.T) {
+	var b strings.Builder
+	w := gemtext.NewWriter(&b)
+	w.Heading(1, "multi\nline")
+	w.Text("plain\n=> not a link")
+	w.Link("gemini://example.org/a b", "label")
+	w.ListItem("item")
+	w.Quote("one\ntwo")
+	w.StartPreformatted("alt")
+	w.PreformattedText("```inner")
+	w.Text("after") // closes the block
+	w.PreformattedText("open")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := "# multi line\n" +
+		"plain\n" +
+		" => not a link\n" +
+		"=> gemini://example.org/a%20b label\n" +
+		"* item\n" +
+		"> one\n" +
+		"> two\n" +
+		"```alt\n" +
+		" ```inner\n" +
+		"```\n" +
+		"after\n" +
+		"```\n" +
+		"open\n" +
+		"```\n"
+	if b.String() != expect {
+		t.Errorf("expected %q, instead found %q", expect, b.String())
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	w := gemtext.NewWriter(new(strings.Builder))
+	if err := w.Heading(4, "x"); !errors.Is(err, gemtext.ErrHeading) {
+		t.Errorf("expected error %q, instead found %q", gemtext.ErrHeading, err)
+	}
+	if err := w.Link(" ", "x"); !errors.Is(err, gemtext.ErrLink) {
+		t.Errorf("expected error %q, instead found %q", gemtext.ErrLink, err)
+	}
+}
+
+func TestWriterResponse(t *testing.T) {
+	r, _ := gemini.NewResponse(gemini.StatusSuccess, gemtext.MediaType)
+	w := gemtext.NewWriter(r)
+	w.Document(testparsed)
+	w.Close()
+	r.Flush()
+
+	body, _ := r.Body()
+	doc := gemtext.ParseString(body)
+	if doc.String() != testparsed.String() {
+		t.Errorf("expected %q, instead found %q", testparsed.String(), doc.String())
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"io"
+	"net"
 
 	"toast.cafe/x/gemini"
 )
@@ -32,6 +34,11 @@ type Client struct {
 	//
 	// If nil, all certs are considered valid for all hosts.
 	Checker CertChecker
+
+	// FailureErrors makes Do return a *gemini.StatusError for failure statuses (4x, 5x and 6x).
+	//
+	// The context is populated regardless.
+	FailureErrors bool
 }
 
 // DefaultClient is the default
@@ -47,14 +54,32 @@ func (c *Client) SetCertificates(certs ...tls.Certificate) {
 }
 
 // Do performs the request in the context, populating it
+//
+// The request must pass gemini.Request.Validate, see also gemini.Request.Canonicalize.
+// If ctx.Titan is set, a titan upload of ctx.Body is performed instead, see Upload.
 func (c *Client) Do(ctx *gemini.Ctx) error {
+	if ctx.Titan != nil {
+		if _, err := gemini.ValidateRequest(ctx.Titan.String()); err != nil {
+			return err
+		}
+	} else if err := ctx.Req.Validate(); err != nil {
+		return err
+	}
+
 	host := c.Proxy
+	addr := c.Proxy
 	if host == "" {
+		// dial the ascii form of the host, which also ends up in SNI
 		host = ctx.Req.Host()
+		port := ctx.Req.URL.Port()
+		if port == "" {
+			port = gemini.DefaultPort
+		}
+		addr = net.JoinHostPort(host, port)
 	}
 
 	// get connection
-	con, err := tls.Dial("tcp", host, c.TLSConfig)
+	con, err := tls.Dial("tcp", addr, c.TLSConfig)
 	if err != nil {
 		return err
 	}
@@ -67,12 +92,21 @@ func (c *Client) Do(ctx *gemini.Ctx) error {
 	}
 
 	// send request
-	// TODO: normalize request
-	fmt.Fprintf(con, "%s\r\n", ctx.Req)
+	if ctx.Titan != nil {
+		if err := sendTitan(con, ctx); err != nil {
+			con.Close()
+			return err
+		}
+	} else {
+		fmt.Fprintf(con, "%s\r\n", ctx.Req)
+	}
 
 	// receive response
 	ctx.Res = new(gemini.Response)
 	err = ctx.Res.FromReader(con)
+	if err == nil && c.FailureErrors && ctx.Status().IsFailure() {
+		return gemini.NewStatusError(ctx.Status(), ctx.Meta())
+	}
 	return err
 }
 
@@ -89,3 +123,30 @@ func (c *Client) Fetch(req string) (*gemini.Ctx, error) {
 
 	return ctx, c.Do(ctx)
 }
+
+// Upload performs a titan upload of size bytes from body to target, returning a populated context
+//
+// The target may use either the gemini or titan scheme, mime and token are optional.
+func (c *Client) Upload(target string, body io.Reader, size int64, mime, token string) (*gemini.Ctx, error) {
+	tr, err := gemini.NewTitanRequest(target, size, mime, token)
+	if err != nil {
+		return nil, err
+	}
+	if canon := tr.Canonicalize(); !canon {
+		return nil, fmt.Errorf("%w: canonicalization failed", gemini.ErrRequest)
+	}
+
+	ctx := &gemini.Ctx{Req: tr.Request, Titan: tr, Body: body}
+	return ctx, c.Do(ctx)
+}
+
+func sendTitan(w io.Writer, ctx *gemini.Ctx) error {
+	if _, err := fmt.Fprintf(w, "%s\r\n", ctx.Titan); err != nil {
+		return err
+	}
+	n, err := io.Copy(w, io.LimitReader(ctx.Body, ctx.Titan.Size))
+	if err == nil && n != ctx.Titan.Size {
+		err = fmt.Errorf("%w: body ended after %d of %d bytes", gemini.ErrRequest, n, ctx.Titan.Size)
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+package gmc_test
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/cert"
+	"toast.cafe/x/gemini/gmc"
+	"toast.cafe/x/gemini/gms"
+)
+
+func TestUpload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gmc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pool, err := cert.NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := pool.Get("localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen:", err)
+	}
+	s := &gms.Server{
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{*c}},
+		Titan:     true,
+		Handler: gms.Titan(gms.TitanHandlerFunc(func(ctx *gemini.Ctx, tr *gemini.TitanRequest, body io.Reader) {
+			b, _ := ioutil.ReadAll(body)
+			ctx.Res.Status = gemini.StatusSuccess
+			ctx.Res.SetMeta("text/plain")
+			ctx.Res.Write(b)
+		}), nil),
+	}
+	go s.ServeListener(l)
+	defer s.Close()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	target := "gemini://localhost:" + port + "/up"
+
+	client := &gmc.Client{TLSConfig: &tls.Config{InsecureSkipVerify: true}}
+	ctx, err := client.Upload(target, strings.NewReader("hello"), 5, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := ctx.Res.Body(); ctx.Status() != gemini.StatusSuccess || body != "hello" {
+		t.Errorf("expected the upload to be echoed, instead found %s %q", ctx.Header(), body)
+	}
+
+	if _, err := client.Upload(target, strings.NewReader("short"), 10, "", ""); !errors.Is(err, gemini.ErrRequest) {
+		t.Errorf("expected %q for a short body, instead found %v", gemini.ErrRequest, err)
+	}
+}
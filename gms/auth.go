@@ -0,0 +1,46 @@
+package gms
+
+import (
+	"time"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/cert"
+)
+
+// RequireCert generates a Handler that only lets requests with a valid client certificate through to next
+//
+// Requests without a certificate get a 60, and those whose certificate is expired or not yet valid get a 62.
+// If authorized is not nil, requests whose certificate fingerprint (see cert.Fingerprint) it refuses get a 61.
+// Only the leaf certificate is considered, as client certificates are usually self-signed.
+func RequireCert(authorized func(fingerprint string) bool, next Handler) HandlerFunc {
+	return func(ctx *gemini.Ctx) {
+		if len(ctx.ClientCerts) == 0 {
+			ctx.Res.Status = gemini.StatusClientCertificateRequires
+			ctx.Res.SetMeta("client certificate required")
+			return
+		}
+		leaf := ctx.ClientCerts[0]
+		if now := time.Now(); now.Before(leaf.NotBefore) || now.After(leaf.NotAfter) {
+			ctx.Res.Status = gemini.StatusCertificateNotValid
+			ctx.Res.SetMeta("certificate expired or not yet valid")
+			return
+		}
+		if authorized != nil && !authorized(cert.Fingerprint(leaf)) {
+			ctx.Res.Status = gemini.StatusCertificateNotAuthorized
+			ctx.Res.SetMeta("certificate not authorized")
+			return
+		}
+		next.ServeGem(ctx)
+	}
+}
+
+// AllowFingerprints returns an authorization function for RequireCert that allows the given fingerprints
+func AllowFingerprints(fingerprints ...string) func(string) bool {
+	allowed := make(map[string]bool, len(fingerprints))
+	for _, v := range fingerprints {
+		allowed[v] = true
+	}
+	return func(fingerprint string) bool {
+		return allowed[fingerprint]
+	}
+}
@@ -0,0 +1,96 @@
+package gms_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/cert"
+	"toast.cafe/x/gemini/gms"
+)
+
+func TestRequireCert(t *testing.T) {
+	now := time.Now()
+	valid := &x509.Certificate{Raw: []byte("valid"), NotBefore: now.Add(-time.Hour), NotAfter: now.Add(time.Hour)}
+	expired := &x509.Certificate{Raw: []byte("expired"), NotBefore: now.Add(-2 * time.Hour), NotAfter: now.Add(-time.Hour)}
+	early := &x509.Certificate{Raw: []byte("early"), NotBefore: now.Add(time.Hour), NotAfter: now.Add(2 * time.Hour)}
+	other := &x509.Certificate{Raw: []byte("other"), NotBefore: now.Add(-time.Hour), NotAfter: now.Add(time.Hour)}
+
+	next := gms.HandlerFunc(func(ctx *gemini.Ctx) {
+		ctx.Res.Status = gemini.StatusSuccess
+	})
+	for _, tc := range []struct {
+		authorized func(string) bool
+		certs      []*x509.Certificate
+		want       gemini.Status
+	}{
+		{nil, nil, gemini.StatusClientCertificateRequires},
+		{nil, []*x509.Certificate{valid}, gemini.StatusSuccess},
+		{nil, []*x509.Certificate{expired}, gemini.StatusCertificateNotValid},
+		{nil, []*x509.Certificate{early}, gemini.StatusCertificateNotValid},
+		{gms.AllowFingerprints(cert.Fingerprint(valid)), []*x509.Certificate{valid}, gemini.StatusSuccess},
+		{gms.AllowFingerprints(cert.Fingerprint(valid)), []*x509.Certificate{other}, gemini.StatusCertificateNotAuthorized},
+		{gms.AllowFingerprints(cert.Fingerprint(valid)), []*x509.Certificate{other, valid}, gemini.StatusCertificateNotAuthorized},
+		{gms.AllowFingerprints(), []*x509.Certificate{valid}, gemini.StatusCertificateNotAuthorized},
+	} {
+		ctx, _ := gemini.NewRequestCtx("gemini://localhost/")
+		ctx.Res = new(gemini.Response)
+		ctx.ClientCerts = tc.certs
+		gms.RequireCert(tc.authorized, next)(ctx)
+		if ctx.Status() != tc.want {
+			t.Errorf("%d certificates: got status %d, want %d", len(tc.certs), ctx.Status(), tc.want)
+		}
+	}
+}
+
+func TestClientCerts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pool, err := cert.NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := pool.Get("client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(client.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := gms.RequireCert(gms.AllowFingerprints(cert.Fingerprint(leaf)), gms.HandlerFunc(func(ctx *gemini.Ctx) {
+		ctx.Res.Status = gemini.StatusSuccess
+		ctx.Res.SetMeta("text/plain")
+		ctx.Res.WriteString(cert.Fingerprint(ctx.ClientCerts[0]))
+	}))
+	addr, _ := testServer(t, &gms.Server{Handler: h})
+	if b, err := request(addr, "gemini://localhost/", *client); err != nil || b != "20 text/plain\r\n"+cert.Fingerprint(leaf) {
+		t.Errorf("with a certificate: got %q %v", b, err)
+	}
+	if b, err := request(addr, "gemini://localhost/"); err != nil || b != "60 client certificate required\r\n" {
+		t.Errorf("without a certificate: got %q %v", b, err)
+	}
+
+	// the certificate is not even asked for
+	addr, _ = testServer(t, &gms.Server{Handler: h, NoClientCerts: true})
+	if b, err := request(addr, "gemini://localhost/", *client); err != nil || b != "60 client certificate required\r\n" {
+		t.Errorf("NoClientCerts: got %q %v", b, err)
+	}
+
+	// stricter settings are kept
+	addr, _ = testServer(t, &gms.Server{Handler: h, TLSConfig: &tls.Config{
+		Certificates: []tls.Certificate{*client},
+		ClientAuth:   tls.RequireAnyClientCert,
+	}})
+	if _, err := request(addr, "gemini://localhost/"); err == nil {
+		t.Error("RequireAnyClientCert: expected the handshake to fail without a certificate")
+	}
+}
@@ -10,6 +10,10 @@ import (
 //
 // ServeGem should write reply headers and data into Ctx.Res and then return.
 // Returning signals that the request is finished; it is not valid to modify Ctx after returning.
+//
+// Responses are buffered and sent after ServeGem returns.
+// Handlers that produce large or long-running bodies can instead set the header and call Ctx.Res.Commit to stream,
+// after which writes go straight to the connection and Ctx.Res.Flush pushes them out.
 type Handler interface {
 	ServeGem(*gemini.Ctx)
 }
@@ -42,3 +46,22 @@ func StripPrefix(prefix string, next Handler) HandlerFunc {
 		next.ServeGem(ctx)
 	}
 }
+
+// InputHandler asks the client for input with the given prompt, calling the given handler once it is provided
+//
+// The handler can retrieve the input with Ctx.Req.Input.
+// If sensitive is true, the client is asked for sensitive input (e.g. a password).
+func InputHandler(prompt string, sensitive bool, next Handler) HandlerFunc {
+	status := gemini.StatusInput
+	if sensitive {
+		status = gemini.StatusInputSensitive
+	}
+	return func(ctx *gemini.Ctx) {
+		if !ctx.Req.HasInput() {
+			ctx.Res.Status = status
+			ctx.Res.SetMeta(prompt)
+			return
+		}
+		next.ServeGem(ctx)
+	}
+}
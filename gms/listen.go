@@ -0,0 +1,132 @@
+package gms
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// A ConnHandler serves a single connection.
+//
+// ServeConn owns the connection, and should close it when done.
+type ConnHandler interface {
+	ServeConn(net.Conn)
+}
+
+// ConnHandlerFunc is an adapter that allows using standalone functions as ConnHandlers
+type ConnHandlerFunc func(net.Conn)
+
+// ServeConn calls f(c)
+func (f ConnHandlerFunc) ServeConn(c net.Conn) {
+	f(c)
+}
+
+// ServeListener accepts connections from l, serving each of them with h in its own goroutine
+//
+// Temporary accept errors are logged to logger (which may be nil) and retried with an increasing delay, up to a second.
+// Anything else stops the loop and is returned, the listener is closed on return.
+// This is the accept loop used by Server, and by the servers of the other protocols in this module.
+func ServeListener(l net.Listener, h ConnHandler, logger Logger) error {
+	defer l.Close()
+	// backoff on temporary accept errors
+	var delay time.Duration
+
+	for { // listening loop
+		conn, err := l.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				if delay == 0 {
+					delay = 5 * time.Millisecond
+				} else {
+					delay *= 2
+				}
+				if delay > time.Second {
+					delay = time.Second
+				}
+				if logger != nil {
+					logger.Printf("error while accepting connection: %s; retrying in %v", err, delay)
+				}
+				time.Sleep(delay)
+				continue
+			}
+			return err
+		}
+		delay = 0
+
+		// handle the connection concurrently
+		go h.ServeConn(conn)
+	}
+}
+
+// A LineHandler responds to a request of a simple line protocol.
+//
+// ServeLine gets the request line without its terminator, and should write the response to w.
+// The connection is closed after ServeLine returns.
+type LineHandler interface {
+	ServeLine(w io.Writer, line string)
+}
+
+// LineHandlerFunc is an adapter that allows using standalone functions as LineHandlers
+type LineHandlerFunc func(io.Writer, string)
+
+// ServeLine calls f(w, line)
+func (f LineHandlerFunc) ServeLine(w io.Writer, line string) {
+	f(w, line)
+}
+
+// DefaultMaxLine is the longest request line a LineServer accepts by default
+const DefaultMaxLine = 4096
+
+// LineServer serves simple line protocols, where the client sends a single line and the server answers and hangs up
+//
+// This covers the likes of finger and nex.
+type LineServer struct {
+	// TCP address to listen on
+	Addr    string
+	Handler LineHandler
+	Logger  Logger
+
+	// MaxLine is the longest accepted request line, DefaultMaxLine if zero.
+	MaxLine int
+}
+
+// Serve listens on Addr and serves requests
+func (s *LineServer) Serve() error {
+	l, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	return s.ServeListener(l)
+}
+
+// ServeListener serves requests from an existing listener
+func (s *LineServer) ServeListener(l net.Listener) error {
+	return ServeListener(l, s, s.Logger)
+}
+
+// ServeConn serves a single request from c, and closes it
+//
+// Lines that are too long or not terminated are dropped without an answer.
+func (s *LineServer) ServeConn(c net.Conn) {
+	defer c.Close()
+	max := s.MaxLine
+	if max <= 0 {
+		max = DefaultMaxLine
+	}
+	line, err := bufio.NewReader(io.LimitReader(c, int64(max))).ReadString('\n')
+	if err != nil {
+		return
+	}
+	line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+	defer func() {
+		if r := recover(); r != nil && s.Logger != nil {
+			s.Logger.Printf("panic while handling connection: %s", r)
+		}
+	}()
+	bw := bufio.NewWriter(c)
+	s.Handler.ServeLine(bw, line)
+	bw.Flush()
+}
@@ -51,13 +51,17 @@ func DomainMux(v Handler) *domainMux {
 // Register registers a given exact domain to call the specific handler.
 //
 // Note that domain matching is exact, and that the empty string will overwrite the "fallback" handler.
+// Domains are normalized, so that registering either the Unicode or punycode spelling matches both.
 func (mux *domainMux) Register(k string, v Handler) {
+	if k != "" {
+		k = gemini.NormalizeHost(k)
+	}
 	mux.kv[k] = v
 }
 
 // ServeGem passes on to the handler registered for a given domain name, else the fallback handler.
 func (mux *domainMux) ServeGem(ctx *gemini.Ctx) {
-	commonExact(mux.kv, ctx.Req.String()).ServeGem(ctx)
+	commonExact(mux.kv, ctx.Req.Host()).ServeGem(ctx)
 }
 
 // ---- by exact path
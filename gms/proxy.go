@@ -0,0 +1,48 @@
+package gms
+
+import (
+	"net"
+	"syscall"
+)
+
+// ErrForbiddenAddress is returned when dialing an address refused by PublicAddressesOnly
+const ErrForbiddenAddress = gmsError("refusing to connect to a non-public address")
+
+// private address ranges, net.IP.IsPrivate is too recent
+var privateNets = []*net.IPNet{
+	{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)},
+	{IP: net.IP{172, 16, 0, 0}, Mask: net.CIDRMask(12, 32)},
+	{IP: net.IP{192, 168, 0, 0}, Mask: net.CIDRMask(16, 32)},
+	{IP: net.IP{100, 64, 0, 0}, Mask: net.CIDRMask(10, 32)}, // carrier-grade nat
+	{IP: net.ParseIP("fc00::"), Mask: net.CIDRMask(7, 128)},
+}
+
+// PublicAddressesOnly is a net.Dialer Control function refusing loopback, private, link-local, unspecified and multicast addresses
+//
+// The proxy handlers of the gopher, finger and nex packages dial with it unless their client has a Control function of its own.
+// Otherwise, anyone could use them to reach services only meant to be reachable from the server's network.
+func PublicAddressesOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return ErrForbiddenAddress
+	}
+	for _, v := range privateNets {
+		if v.Contains(ip) {
+			return ErrForbiddenAddress
+		}
+	}
+	return nil
+}
+
+// ProxyDialer returns d, using PublicAddressesOnly as its Control function if it has none
+func ProxyDialer(d net.Dialer) net.Dialer {
+	if d.Control == nil {
+		d.Control = PublicAddressesOnly
+	}
+	return d
+}
@@ -1,25 +1,80 @@
 package gms
 
 import (
+	"bufio"
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"sync"
+	"time"
 
 	"toast.cafe/x/gemini"
 )
 
+type gmsError string
+
+func (e gmsError) Error() string { return string(e) }
+
+// ErrServerClosed is returned by Serve after a call to Shutdown or Close
+const ErrServerClosed = gmsError("server closed")
+
 type Logger interface {
 	// Printf must have the same semantics as log.Printf, including the sync
 	Printf(string, ...interface{})
 }
 
+// Server is a gemini server
+//
+// For virtual hosting, set TLSConfig.GetCertificate to the GetCertificate method of a cert.Pool, see its HostPolicy.
+//
+// Handlers must set a valid response status, clients get a 42 otherwise.
+// Handlers that panic get a 40 response if they had not committed yet, and have their connection closed.
 type Server struct {
 	// TCP address to listen on, defaults to :1965
 	Addr      string
-	logger    Logger
+	Logger    Logger
 	TLSConfig *tls.Config
-	handler   Handler // TODO: use a default handler?
+	Handler   Handler // TODO: use a default handler?
+
+	// Strict rejects requests that do not conform to the spec, see gemini.ValidateRequest.
+	Strict bool
+
+	// Titan accepts titan uploads, which reach the handler with Ctx.Titan and Ctx.Body set.
+	//
+	// See Titan for a handler that dispatches them.
+	Titan bool
+	// TitanMaxSize refuses titan uploads larger than this many bytes, 0 means no limit.
+	TitanMaxSize int64
+
+	// NoClientCerts stops asking clients for a certificate, which they are by default, see ServeListener.
+	NoClientCerts bool
+
+	// AllowProxy accepts requests for other hosts than the TLS server name (SNI), which are refused with a 53 otherwise.
+	//
+	// Proxying handlers, such as gopher.ProxyHandler, need this.
+	AllowProxy bool
+
+	// HandshakeTimeout bounds the TLS handshake, 0 means no limit.
+	HandshakeTimeout time.Duration
+	// ReadTimeout bounds reading the request line, 0 means no limit.
+	ReadTimeout time.Duration
+	// HandlerTimeout bounds the handler, including streamed responses and titan uploads, 0 means no limit.
+	//
+	// Handlers that time out get a 40 response if they had not committed yet, and have their connection closed.
+	// They keep running in the background, but their writes fail.
+	HandlerTimeout time.Duration
+	// WriteTimeout bounds writing the response once the handler returns, 0 means no limit.
+	WriteTimeout time.Duration
+
+	mu        sync.Mutex
+	closed    bool
+	killed    bool          // connections were closed, see Close
+	idle      chan struct{} // closed once the last connection is done, see Shutdown
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
 }
 
 var DefaultServer = &Server{
@@ -30,52 +85,339 @@ var DefaultServer = &Server{
 }
 
 func (s *Server) log(fmt string, args ...interface{}) {
-	if s.logger != nil {
-		s.logger.Printf(fmt, args)
+	if s.Logger != nil {
+		s.Logger.Printf(fmt, args...)
 	}
 }
 
+// Serve listens on Addr and serves requests
+//
+// It always returns a non-nil error, ErrServerClosed after a call to Shutdown or Close.
 func (s *Server) Serve() error {
-	l, err := tls.Listen("tcp", s.Addr, s.TLSConfig)
+	l, err := net.Listen("tcp", s.Addr)
 	if err != nil {
 		return err
 	}
-	defer l.Close()
+	return s.ServeListener(l)
+}
+
+// ServeListener serves requests from an existing (plain TCP) listener, see Serve
+//
+// Client certificates are requested, but not verified, unless NoClientCerts is set.
+// As tls.NoClientCert is the zero value, TLSConfig.ClientAuth can only make the requirements stricter.
+func (s *Server) ServeListener(l net.Listener) error {
+	conf := s.TLSConfig.Clone()
+	if conf == nil {
+		conf = new(tls.Config)
+	}
+	if conf.ClientAuth == tls.NoClientCert && !s.NoClientCerts {
+		conf.ClientAuth = tls.RequestClientCert // self-signed, see RequireCert
+	}
+	l = tls.NewListener(l, conf)
+	if !s.trackListener(l, true) {
+		l.Close()
+		return ErrServerClosed
+	}
+	defer s.trackListener(l, false)
+
+	err := ServeListener(trackingListener{l, s}, ConnHandlerFunc(s.serveTracked), s.Logger)
+	if s.isClosed() {
+		return ErrServerClosed
+	}
+	return err
+}
+
+// Shutdown gracefully shuts the server down
+//
+// It stops accepting connections, then waits for the active ones to finish.
+// If ctx expires first, the remaining connections are closed and ctx.Err() is returned.
+// Once shut down, a server cannot be reused.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.closeListeners()
+
+	s.mu.Lock()
+	if len(s.conns) == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	if s.idle == nil {
+		s.idle = make(chan struct{})
+	}
+	idle := s.idle
+	s.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		s.closeConns()
+		return ctx.Err()
+	}
+}
 
-	for { // listening loop
-		conn, err := l.Accept()
-		if err != nil {
-			s.log("error while accepting connection: %s", err)
+// Close immediately closes the listeners and all active connections
+//
+// Once closed, a server cannot be reused, see also Shutdown.
+func (s *Server) Close() error {
+	err := s.closeListeners()
+	s.closeConns()
+	return err
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// trackListener registers or unregisters a listener, returning false if the server is already closed
+func (s *Server) trackListener(l net.Listener, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !add {
+		delete(s.listeners, l)
+		return true
+	}
+	if s.closed {
+		return false
+	}
+	if s.listeners == nil {
+		s.listeners = make(map[net.Listener]struct{})
+	}
+	s.listeners[l] = struct{}{}
+	return true
+}
+
+// trackingListener registers connections with the server as they are accepted
+//
+// Connections accepted before a Shutdown are then waited for, even if their goroutine has not started yet.
+type trackingListener struct {
+	net.Listener
+	s *Server
+}
+
+func (l trackingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	l.s.trackConn(c)
+	return c, nil
+}
+
+// trackConn registers c, closing it right away if the connections were already closed
+func (s *Server) trackConn(c net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.killed {
+		c.Close() // serveConn fails and untracks it
+	}
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]struct{})
+	}
+	s.conns[c] = struct{}{}
+}
+
+// serveTracked serves c, then unregisters it
+func (s *Server) serveTracked(c net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		if len(s.conns) == 0 && s.idle != nil {
+			close(s.idle)
+			s.idle = nil
 		}
+		s.mu.Unlock()
+	}()
+	s.serveConn(c)
+}
 
-		// handle the connection concurrently
-		go func(c net.Conn) {
-			defer c.Close()
+func (s *Server) closeListeners() (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for l := range s.listeners {
+		if cerr := l.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
 
-			ctx := &gemini.Ctx{}
-			ctx.Req, err = gemini.ReadRequest(c)
-			if err != nil {
-				fmt.Fprintf(c, "%d\r\n", gemini.StatusBadRequest)
+func (s *Server) closeConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.killed = true
+	for c := range s.conns {
+		c.Close()
+	}
+}
+
+// titan request errors, on top of the ones from gemini.TitanRequest
+const (
+	errTitanDisabled = gemini.RequestError("titan uploads are not accepted")
+	errTitanTooLarge = gemini.RequestError("titan upload is too large")
+)
+
+// prepareTitan populates the titan-specific parts of the context
+func (s *Server) prepareTitan(ctx *gemini.Ctx, br *bufio.Reader) error {
+	if !s.Titan {
+		return errTitanDisabled
+	}
+	tr, err := ctx.Req.Titan()
+	if err != nil {
+		return err
+	}
+	if s.TitanMaxSize > 0 && tr.Size > s.TitanMaxSize {
+		return errTitanTooLarge
+	}
+	ctx.Titan = tr
+	ctx.Req = tr.Request // so that muxes see the real path
+	ctx.Body = io.LimitReader(br, tr.Size)
+	return nil
+}
+
+// serveConn handles a single connection
+//
+// Responses are buffered unless the handler commits them, see gemini.Response.Commit.
+func (s *Server) serveConn(c net.Conn) {
+	defer c.Close()
+
+	var state tls.ConnectionState
+	if tc, ok := c.(*tls.Conn); ok {
+		setDeadline(c.SetDeadline, s.HandshakeTimeout)
+		if err := tc.Handshake(); err != nil {
+			if isTimeout(err) {
+				s.log("timeout during tls handshake with %s", c.RemoteAddr())
+			}
+			return
+		}
+		c.SetDeadline(time.Time{})
+		state = tc.ConnectionState()
+	}
+
+	ctx := &gemini.Ctx{ClientCerts: state.PeerCertificates}
+	br := bufio.NewReader(c) // titan requests have a body after the request line
+	var err error
+	setDeadline(c.SetReadDeadline, s.ReadTimeout)
+	if s.Strict {
+		ctx.Req, err = gemini.ReadRequestStrict(br)
+	} else {
+		ctx.Req, err = gemini.ReadRequest(br)
+	}
+	c.SetReadDeadline(time.Time{})
+	if isTimeout(err) {
+		s.log("timeout reading request from %s", c.RemoteAddr())
+		return
+	}
+	if err == nil && ctx.Req.IsTitan() {
+		err = s.prepareTitan(ctx, br)
+	}
+	if err != nil {
+		var reason gemini.RequestError
+		if errors.As(err, &reason) {
+			fmt.Fprintf(c, "%d %s\r\n", gemini.StatusBadRequest, reason)
+		} else {
+			fmt.Fprintf(c, "%d bad request\r\n", gemini.StatusBadRequest)
+		}
+		return
+	}
+
+	// clients that connect to an ip address send no server name
+	if sni := state.ServerName; sni != "" && !s.AllowProxy && gemini.NormalizeHost(sni) != ctx.Req.Host() {
+		fmt.Fprintf(c, "%d host does not match the tls server name\r\n", gemini.StatusProxyRequestRefused)
+		return
+	}
+
+	// prepare response
+	tw := &timeoutWriter{c: c}
+	ctx.Res = resPool.Get().(*gemini.Response)
+	ctx.Res.ServerPrepareStream(tw)
+
+	// mux it
+	switch s.handle(ctx, tw) {
+	case handlerTimeout:
+		s.log("handler timed out after %v serving %s to %s", s.HandlerTimeout, ctx.Req, c.RemoteAddr())
+		return // the handler still has the response, don't recycle it
+	case handlerPanic:
+		if !ctx.Res.Committed() {
+			setDeadline(c.SetWriteDeadline, s.WriteTimeout)
+			fmt.Fprintf(c, "%d internal error\r\n", gemini.StatusTemporaryFailure)
+		}
+		return // the response is in an unknown state, don't recycle it
+	}
+	defer resPool.Put(ctx.Res)
+	defer ctx.Res.Reset()
+
+	setDeadline(c.SetWriteDeadline, s.WriteTimeout)
+	ctx.Res.Flush()
+
+	// the handler streamed it already
+	if ctx.Res.Committed() {
+		if err := ctx.Res.Err(); isTimeout(err) {
+			s.log("timeout writing response to %s", c.RemoteAddr())
+		} else if err != nil {
+			s.log("error while streaming response: %s", err)
+		}
+		return
+	}
+
+	// write it
+	if status := ctx.Status(); !status.Valid() {
+		s.log("invalid response status %d for %s", status, ctx.Req)
+		_, err = fmt.Fprintf(c, "%d invalid response status\r\n", gemini.StatusCGIError)
+	} else {
+		_, err = fmt.Fprintf(c, "%d %s\r\n", status, ctx.Meta())
+		if err == nil {
+			_, err = io.Copy(c, ctx.Res)
+		}
+	}
+	if isTimeout(err) {
+		s.log("timeout writing response to %s", c.RemoteAddr())
+	}
+}
+
+// outcomes of handle
+const (
+	handlerDone = iota
+	handlerPanic
+	handlerTimeout
+)
+
+// handle runs the handler, within HandlerTimeout if set
+func (s *Server) handle(ctx *gemini.Ctx, tw *timeoutWriter) int {
+	run := func() (outcome int) {
+		defer func() {
+			if r := recover(); r != nil {
+				s.log("panic while handling connection: %s", r)
+				outcome = handlerPanic
 			}
+		}()
+		s.Handler.ServeGem(ctx)
+		return handlerDone
+	}
+	if s.HandlerTimeout <= 0 {
+		return run()
+	}
+
+	done := make(chan int, 1)
+	go func() {
+		done <- run()
+	}()
+	timer := time.NewTimer(s.HandlerTimeout)
+	defer timer.Stop()
+	select {
+	case outcome := <-done:
+		return outcome
+	case <-timer.C:
+		tw.timeout(fmt.Sprintf("%d handler timed out\r\n", gemini.StatusTemporaryFailure))
+		return handlerTimeout
+	}
+}
 
-			// prepare response
-			ctx.Res = resPool.Get().(*gemini.Response)
-			defer resPool.Put(ctx.Res)
-			defer ctx.Res.Reset()
-			ctx.Res.ServerPrepare()
-
-			// mux it
-			defer func() {
-				if r := recover(); r != nil {
-					s.log("panic while handling connection: %s", r)
-				}
-			}()
-			s.handler.ServeGem(ctx)
-			ctx.Res.Flush()
-
-			// write it
-			fmt.Fprintf(c, "%d %s\r\n", ctx.Status, ctx.Meta())
-			io.Copy(c, ctx.Res)
-		}(conn)
+// setDeadline calls set with a deadline d from now, if d is set
+func setDeadline(set func(time.Time) error, d time.Duration) {
+	if d > 0 {
+		set(time.Now().Add(d))
 	}
 }
@@ -0,0 +1,178 @@
+package gms_test
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/cert"
+	"toast.cafe/x/gemini/gms"
+)
+
+// testServer serves s on a loopback listener with a throwaway certificate for localhost
+//
+// It returns the address, and a channel that gets the result of ServeListener.
+func testServer(t *testing.T, s *gms.Server) (string, <-chan error) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "gms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	pool, err := cert.NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := pool.Get("localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.TLSConfig == nil {
+		s.TLSConfig = &tls.Config{Certificates: []tls.Certificate{*c}}
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen:", err)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- s.ServeListener(l) }()
+	t.Cleanup(func() { s.Close() })
+	return l.Addr().String(), errc
+}
+
+// request sends a request line to the server at addr, for server name localhost, and returns the whole response
+func request(addr, line string, certs ...tls.Certificate) (string, error) {
+	con, err := tls.Dial("tcp", addr, &tls.Config{ServerName: "localhost", InsecureSkipVerify: true, Certificates: certs})
+	if err != nil {
+		return "", err
+	}
+	defer con.Close()
+	con.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := con.Write([]byte(line + "\r\n")); err != nil {
+		return "", err
+	}
+	b, err := ioutil.ReadAll(con)
+	return string(b), err
+}
+
+func TestShutdown(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	s := &gms.Server{Handler: gms.HandlerFunc(func(ctx *gemini.Ctx) {
+		close(started)
+		<-release
+		ctx.Res.Status = gemini.StatusSuccess
+		ctx.Res.SetMeta("text/gemini")
+		ctx.Res.WriteString("done")
+	})}
+	addr, errc := testServer(t, s)
+
+	res := make(chan string, 1)
+	go func() {
+		b, _ := request(addr, "gemini://localhost/")
+		res <- b
+	}()
+	<-started
+
+	done := make(chan error, 1)
+	go func() { done <- s.Shutdown(context.Background()) }()
+	if err := <-errc; err != gms.ErrServerClosed {
+		t.Errorf("expected ServeListener to return %q, instead found %v", gms.ErrServerClosed, err)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("Shutdown returned %v with a request in flight", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("Shutdown: %s", err)
+	}
+	if b := <-res; b != "20 text/gemini\r\ndone" {
+		t.Errorf("expected the in-flight request to finish, instead found %q", b)
+	}
+	if _, err := request(addr, "gemini://localhost/"); err == nil {
+		t.Error("expected new connections to fail after Shutdown")
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	s := &gms.Server{Handler: gms.HandlerFunc(func(ctx *gemini.Ctx) {
+		close(started)
+		<-release
+	})}
+	addr, _ := testServer(t, s)
+
+	res := make(chan error, 1)
+	go func() {
+		_, err := request(addr, "gemini://localhost/")
+		res <- err
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected %q, instead found %v", context.DeadlineExceeded, err)
+	}
+	select {
+	case <-res: // closed under the handler's feet
+	case <-time.After(time.Second):
+		t.Error("expected the connection to be closed once the deadline passed")
+	}
+}
+
+func TestServeClosed(t *testing.T) {
+	s := &gms.Server{Addr: "127.0.0.1:0", Handler: gms.HandlerFunc(func(ctx *gemini.Ctx) {})}
+	errc := make(chan error, 1)
+	go func() { errc <- s.Serve() }()
+	time.Sleep(10 * time.Millisecond) // let it listen
+	s.Close()
+	if err := <-errc; err != gms.ErrServerClosed {
+		t.Errorf("expected Serve to return %q, instead found %v", gms.ErrServerClosed, err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen:", err)
+	}
+	if err := s.ServeListener(l); err != gms.ErrServerClosed {
+		t.Errorf("expected ServeListener on a closed server to return %q, instead found %v", gms.ErrServerClosed, err)
+	}
+	if _, err := l.Accept(); err == nil {
+		t.Error("expected the listener to be closed")
+	}
+}
+
+func TestServerName(t *testing.T) {
+	h := gms.HandlerFunc(func(ctx *gemini.Ctx) {
+		ctx.Res.Status = gemini.StatusSuccess
+		ctx.Res.SetMeta("text/plain")
+		ctx.Res.WriteString(ctx.Req.Host())
+	})
+	addr, _ := testServer(t, &gms.Server{Handler: h})
+	for _, tc := range []struct {
+		req, want string
+	}{
+		{"gemini://localhost/", "20 text/plain\r\nlocalhost"},
+		{"gemini://LocalHost.:1965/", "20 text/plain\r\nlocalhost"},
+		{"gemini://example.org/", "53 host does not match the tls server name\r\n"},
+	} {
+		if b, err := request(addr, tc.req); err != nil || b != tc.want {
+			t.Errorf("%s: got %q %v, want %q", tc.req, b, err, tc.want)
+		}
+	}
+
+	addr, _ = testServer(t, &gms.Server{Handler: h, AllowProxy: true})
+	if b, err := request(addr, "gemini://example.org/"); err != nil || b != "20 text/plain\r\nexample.org" {
+		t.Errorf("proxy: got %q %v", b, err)
+	}
+}
@@ -0,0 +1,265 @@
+package gms
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/cert"
+)
+
+// session errors
+const (
+	ErrNoCert     = gmsError("client certificate required")
+	ErrCertLinked = gmsError("certificate is already linked to an account")
+	ErrLinkCode   = gmsError("invalid or expired link code")
+	ErrLastCert   = gmsError("cannot unlink the last certificate of an account")
+	ErrNotLinked  = gmsError("certificate is not linked to this account")
+	ErrNoSession  = gmsError("session does not exist")
+)
+
+// how long link codes are valid for by default
+const defaultLinkLife = 10 * time.Minute
+
+// Session is the state of an account, shared by all of the client certificates linked to it
+type Session struct {
+	ID      string
+	Certs   []string          // fingerprints of the linked certificates, see cert.Fingerprint
+	Data    map[string]string // free for handlers to use, persisted by Sessions.Save
+	Created time.Time
+	Expires time.Time // zero means no expiry
+}
+
+// Expired returns true if the session has expired
+func (s *Session) Expired() bool {
+	return !s.Expires.IsZero() && time.Now().After(s.Expires)
+}
+
+func (s *Session) clone() *Session {
+	out := *s
+	out.Certs = append([]string(nil), s.Certs...)
+	out.Data = make(map[string]string, len(s.Data))
+	for k, v := range s.Data {
+		out.Data[k] = v
+	}
+	return &out
+}
+
+// SessionFrom returns the session attached to the context by Sessions.Handler, or nil
+func SessionFrom(ctx *gemini.Ctx) *Session {
+	s, _ := ctx.Session.(*Session)
+	return s
+}
+
+// Sessions manages accounts keyed by client certificate fingerprint
+//
+// Accounts are created with Register, and further certificates are linked to them with NewLinkCode and Link.
+// Changes to the Data of a session are only kept once passed to Save.
+//
+// Link codes are only kept in memory, so they don't survive a restart, and aren't shared with other processes using the same store.
+type Sessions struct {
+	Store SessionStore
+
+	// TTL is how long sessions live after they were last saved, 0 means forever.
+	TTL time.Duration
+	// LinkCodeTTL is how long link codes stay valid, 10 minutes if 0.
+	LinkCodeTTL time.Duration
+
+	mu    sync.Mutex
+	codes map[string]linkCode
+}
+
+type linkCode struct {
+	id      string
+	expires time.Time
+}
+
+// fingerprint returns the fingerprint of the leaf client certificate
+func fingerprint(ctx *gemini.Ctx) (string, error) {
+	if len(ctx.ClientCerts) == 0 {
+		return "", ErrNoCert
+	}
+	return cert.Fingerprint(ctx.ClientCerts[0]), nil
+}
+
+// Lookup returns the session linked to the client certificate of the request, or nil
+//
+// Expired sessions are deleted, and nil is returned for them.
+func (m *Sessions) Lookup(ctx *gemini.Ctx) (*Session, error) {
+	if len(ctx.ClientCerts) == 0 {
+		return nil, nil
+	}
+	s, err := m.Store.Lookup(cert.Fingerprint(ctx.ClientCerts[0]))
+	if err != nil || s == nil {
+		return nil, err
+	}
+	if s.Expired() {
+		return nil, m.Store.Delete(s.ID)
+	}
+	return s, nil
+}
+
+// Handler generates a Handler that attaches the session of the request to the context before calling next
+//
+// Requests without a certificate or session reach next with no session, see Require.
+// Storage errors are answered with a 40.
+func (m *Sessions) Handler(next Handler) HandlerFunc {
+	return func(ctx *gemini.Ctx) {
+		s, err := m.Lookup(ctx)
+		if err != nil {
+			ctx.Res.Status = gemini.StatusTemporaryFailure
+			ctx.Res.SetMeta("session storage unavailable")
+			return
+		}
+		if s != nil {
+			ctx.Session = s
+		}
+		next.ServeGem(ctx)
+	}
+}
+
+// Require generates a Handler that only lets requests with a session through to next
+//
+// Requests without a certificate get a 60, and those whose certificate is not linked to an account get a 61.
+// It includes Handler, so there's no need to wrap next in both.
+func (m *Sessions) Require(next Handler) HandlerFunc {
+	return m.Handler(HandlerFunc(func(ctx *gemini.Ctx) {
+		switch {
+		case len(ctx.ClientCerts) == 0:
+			ctx.Res.Status = gemini.StatusClientCertificateRequires
+			ctx.Res.SetMeta("client certificate required")
+		case SessionFrom(ctx) == nil:
+			ctx.Res.Status = gemini.StatusCertificateNotAuthorized
+			ctx.Res.SetMeta("certificate not registered")
+		default:
+			next.ServeGem(ctx)
+		}
+	}))
+}
+
+// Register creates a new account for the client certificate of the request, and attaches it to the context
+func (m *Sessions) Register(ctx *gemini.Ctx) (*Session, error) {
+	fp, err := fingerprint(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s, err := m.Lookup(ctx); err != nil {
+		return nil, err
+	} else if s != nil {
+		return nil, ErrCertLinked
+	}
+
+	id, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{ID: id, Certs: []string{fp}, Data: make(map[string]string), Created: time.Now()}
+	if m.TTL > 0 {
+		s.Expires = s.Created.Add(m.TTL)
+	}
+	if err := m.Store.Create(s); err != nil { // another request may have registered it since
+		return nil, err
+	}
+	ctx.Session = s
+	return s, nil
+}
+
+// Save stores the Data of the session, extending its expiry by TTL
+//
+// Its Certs are left as stored, see Link and Unlink.
+// Saving a session that was deleted in the meantime fails with ErrNoSession.
+func (m *Sessions) Save(s *Session) error {
+	if m.TTL > 0 {
+		s.Expires = time.Now().Add(m.TTL)
+	}
+	return m.Store.Save(s)
+}
+
+// Delete deletes the session, unlinking all of its certificates
+func (m *Sessions) Delete(s *Session) error {
+	return m.Store.Delete(s.ID)
+}
+
+// NewLinkCode returns a single-use code that links another certificate to the session, see Link
+func (m *Sessions) NewLinkCode(s *Session) (string, error) {
+	code, err := randomID()
+	if err != nil {
+		return "", err
+	}
+	life := m.LinkCodeTTL
+	if life <= 0 {
+		life = defaultLinkLife
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.codes == nil {
+		m.codes = make(map[string]linkCode)
+	}
+	now := time.Now()
+	for k, v := range m.codes { // drop stale codes
+		if now.After(v.expires) {
+			delete(m.codes, k)
+		}
+	}
+	m.codes[code] = linkCode{id: s.ID, expires: now.Add(life)}
+	return code, nil
+}
+
+// Link links the client certificate of the request to the account the code was made for, and attaches it to the context
+func (m *Sessions) Link(ctx *gemini.Ctx, code string) (*Session, error) {
+	fp, err := fingerprint(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s, err := m.Lookup(ctx); err != nil {
+		return nil, err
+	} else if s != nil {
+		return nil, ErrCertLinked
+	}
+
+	m.mu.Lock()
+	lc, ok := m.codes[code]
+	delete(m.codes, code)
+	m.mu.Unlock()
+	if !ok || time.Now().After(lc.expires) {
+		return nil, ErrLinkCode
+	}
+
+	s, err := m.Store.Get(lc.id)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil || s.Expired() {
+		return nil, ErrLinkCode
+	}
+	if s, err = m.Store.Link(s.ID, fp); err != nil { // another request may have linked it since
+		return nil, err
+	} else if s == nil {
+		return nil, ErrLinkCode
+	}
+	ctx.Session = s
+	return s, nil
+}
+
+// Unlink removes a certificate from the session, refusing to remove the last one
+func (m *Sessions) Unlink(s *Session, fingerprint string) error {
+	u, err := m.Store.Unlink(s.ID, fingerprint)
+	if err != nil {
+		return err
+	} else if u == nil {
+		return ErrNoSession
+	}
+	s.Certs = u.Certs
+	return nil
+}
+
+func randomID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
@@ -0,0 +1,268 @@
+package gms_test
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/cert"
+	"toast.cafe/x/gemini/gms"
+)
+
+// certCtx returns a request context with a fake client certificate
+func certCtx(raw string) *gemini.Ctx {
+	ctx, _ := gemini.NewRequestCtx("gemini://localhost/")
+	ctx.Res = new(gemini.Response)
+	if raw != "" {
+		ctx.ClientCerts = []*x509.Certificate{{Raw: []byte(raw)}}
+	}
+	return ctx
+}
+
+func fp(raw string) string {
+	return cert.Fingerprint(&x509.Certificate{Raw: []byte(raw)})
+}
+
+func TestSessions(t *testing.T) {
+	m := &gms.Sessions{Store: gms.NewMemorySessionStore()}
+
+	if _, err := m.Register(certCtx("")); err != gms.ErrNoCert {
+		t.Errorf("expected %q without a certificate, instead found %v", gms.ErrNoCert, err)
+	}
+	ctx := certCtx("a")
+	s, err := m.Register(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gms.SessionFrom(ctx) != s || len(s.Certs) != 1 || s.Certs[0] != fp("a") {
+		t.Errorf("got session %+v", s)
+	}
+	if _, err := m.Register(certCtx("a")); err != gms.ErrCertLinked {
+		t.Errorf("expected %q registering twice, instead found %v", gms.ErrCertLinked, err)
+	}
+
+	// link b
+	code, err := m.NewLinkCode(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Link(certCtx("a"), code); err != gms.ErrCertLinked {
+		t.Errorf("expected %q linking a linked certificate, instead found %v", gms.ErrCertLinked, err)
+	}
+	if l, err := m.Link(certCtx("b"), code); err != nil || l.ID != s.ID || len(l.Certs) != 2 {
+		t.Errorf("link: got %+v %v", l, err)
+	}
+	if _, err := m.Link(certCtx("c"), code); err != gms.ErrLinkCode {
+		t.Errorf("expected %q reusing a code, instead found %v", gms.ErrLinkCode, err)
+	}
+	if l, err := m.Lookup(certCtx("b")); err != nil || l == nil || l.ID != s.ID {
+		t.Errorf("lookup: got %+v %v", l, err)
+	}
+
+	// unlink it again
+	s, _ = m.Lookup(certCtx("a"))
+	if err := m.Unlink(s, fp("c")); err != gms.ErrNotLinked {
+		t.Errorf("expected %q unlinking an unknown certificate, instead found %v", gms.ErrNotLinked, err)
+	}
+	if err := m.Unlink(s, fp("b")); err != nil {
+		t.Fatal(err)
+	}
+	if l, err := m.Lookup(certCtx("b")); err != nil || l != nil {
+		t.Errorf("expected no session for an unlinked certificate, instead found %+v %v", l, err)
+	}
+	if err := m.Unlink(s, fp("a")); err != gms.ErrLastCert {
+		t.Errorf("expected %q unlinking the last certificate, instead found %v", gms.ErrLastCert, err)
+	}
+}
+
+func TestSessionsSave(t *testing.T) {
+	m := &gms.Sessions{Store: gms.NewMemorySessionStore()}
+	s, err := m.Register(certCtx("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, _ := m.Lookup(certCtx("a"))
+	code, _ := m.NewLinkCode(s)
+	if _, err := m.Link(certCtx("b"), code); err != nil {
+		t.Fatal(err)
+	}
+
+	// a stale copy only saves its data
+	stale.Data["name"] = "alice"
+	if err := m.Save(stale); err != nil {
+		t.Fatal(err)
+	}
+	if l, err := m.Lookup(certCtx("b")); err != nil || l == nil || l.Data["name"] != "alice" || len(l.Certs) != 2 {
+		t.Errorf("expected the link to survive a stale save, instead found %+v %v", l, err)
+	}
+
+	if err := m.Delete(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Save(stale); err != gms.ErrNoSession {
+		t.Errorf("expected %q saving a deleted session, instead found %v", gms.ErrNoSession, err)
+	}
+	if l, err := m.Lookup(certCtx("a")); err != nil || l != nil {
+		t.Errorf("expected the deleted session to stay deleted, instead found %+v %v", l, err)
+	}
+}
+
+// slowStore widens the window between looking a certificate up and saving it
+type slowStore struct {
+	*gms.MemorySessionStore
+}
+
+func (s slowStore) Lookup(fingerprint string) (*gms.Session, error) {
+	defer time.Sleep(5 * time.Millisecond)
+	return s.MemorySessionStore.Lookup(fingerprint)
+}
+
+func TestSessionsConcurrent(t *testing.T) {
+	m := &gms.Sessions{Store: slowStore{gms.NewMemorySessionStore()}}
+	owner, err := m.Register(certCtx("owner"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes := make([]string, 8)
+	for i := range codes {
+		codes[i], _ = m.NewLinkCode(owner)
+	}
+
+	var mu sync.Mutex
+	var registered, linked int
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := m.Register(certCtx("new")); err == nil {
+				mu.Lock()
+				registered++
+				mu.Unlock()
+			}
+		}()
+		go func(code string) {
+			defer wg.Done()
+			if _, err := m.Link(certCtx("other"), code); err == nil {
+				mu.Lock()
+				linked++
+				mu.Unlock()
+			}
+		}(codes[i])
+	}
+	wg.Wait()
+	if registered != 1 || linked != 1 {
+		t.Errorf("expected a single registration and link, instead found %d and %d", registered, linked)
+	}
+}
+
+func TestSessionsExpiry(t *testing.T) {
+	store := gms.NewMemorySessionStore()
+	m := &gms.Sessions{Store: store, TTL: 10 * time.Millisecond, LinkCodeTTL: 10 * time.Millisecond}
+	s, err := m.Register(certCtx("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := m.NewLinkCode(s)
+	time.Sleep(20 * time.Millisecond)
+
+	if _, err := m.Link(certCtx("b"), code); err != gms.ErrLinkCode {
+		t.Errorf("expected %q for an expired code, instead found %v", gms.ErrLinkCode, err)
+	}
+	if l, err := m.Lookup(certCtx("a")); err != nil || l != nil {
+		t.Errorf("expected no session once expired, instead found %+v %v", l, err)
+	}
+	if l, _ := store.Get(s.ID); l != nil {
+		t.Error("expected the expired session to be deleted")
+	}
+	if _, err := m.Register(certCtx("a")); err != nil {
+		t.Errorf("expected to register again once expired, instead found %v", err)
+	}
+}
+
+func TestFileSessionStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sub", "sessions.json")
+
+	store, err := gms.NewFileSessionStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &gms.Sessions{Store: store}
+	s, err := m.Register(certCtx("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Data["name"] = "alice"
+	if err := m.Save(s); err != nil {
+		t.Fatal(err)
+	}
+	code, _ := m.NewLinkCode(s)
+	if _, err := m.Link(certCtx("b"), code); err != nil {
+		t.Fatal(err)
+	}
+	other, err := m.Register(certCtx("c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Delete(other); err != nil {
+		t.Fatal(err)
+	}
+	if fs, _ := ioutil.ReadDir(filepath.Dir(path)); len(fs) != 1 || fs[0].Name() != "sessions.json" {
+		t.Errorf("expected only the sessions file to be left, instead found %v", fs)
+	}
+
+	store, err = gms.NewFileSessionStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := store.Lookup(fp("b"))
+	if err != nil || l == nil {
+		t.Fatalf("expected the session to be loaded, instead found %+v %v", l, err)
+	}
+	if l.ID != s.ID || l.Data["name"] != "alice" || len(l.Certs) != 2 || !l.Created.Equal(s.Created) {
+		t.Errorf("got %+v, want %+v", l, s)
+	}
+	if l, err := store.Get(other.ID); err != nil || l != nil {
+		t.Errorf("expected the deleted session to stay deleted, instead found %+v %v", l, err)
+	}
+
+	// failed writes are undone
+	if err := os.Mkdir(path+".tmp", 0700); err != nil {
+		t.Fatal(err)
+	}
+	m = &gms.Sessions{Store: store}
+	if _, err := m.Register(certCtx("d")); err == nil {
+		t.Fatal("expected registering to fail when the file can't be written")
+	}
+	if l, err := store.Lookup(fp("d")); err != nil || l != nil {
+		t.Errorf("expected no session after a failed write, instead found %+v %v", l, err)
+	}
+	l.Data["name"] = "bob"
+	if err := m.Save(l); err == nil {
+		t.Fatal("expected saving to fail when the file can't be written")
+	}
+	if l, _ := store.Get(s.ID); l.Data["name"] != "alice" {
+		t.Errorf("expected the failed save to be undone, instead found %+v", l)
+	}
+	if err := m.Unlink(l, fp("b")); err == nil {
+		t.Fatal("expected unlinking to fail when the file can't be written")
+	}
+	if l, _ := store.Lookup(fp("b")); l == nil || l.ID != s.ID {
+		t.Errorf("expected the failed unlink to be undone, instead found %+v", l)
+	}
+
+	os.Remove(path + ".tmp")
+	if _, err := m.Register(certCtx("d")); err != nil {
+		t.Errorf("expected registering to work again, instead found %v", err)
+	}
+}
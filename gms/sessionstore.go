@@ -0,0 +1,335 @@
+package gms
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// SessionStore stores sessions for Sessions
+//
+// Implementations must be safe for concurrent use, and must not let callers modify stored sessions in place.
+type SessionStore interface {
+	// Get returns the session with the given id, or nil if there is none.
+	Get(id string) (*Session, error)
+	// Lookup returns the session the fingerprint is linked to, or nil if there is none.
+	Lookup(fingerprint string) (*Session, error)
+	// Save updates the Data and Expires of the stored session, keeping its Certs, or fails with ErrNoSession if there is none.
+	Save(s *Session) error
+	// Create saves a new session, unless one of its Certs is linked to another session already, which is an ErrCertLinked.
+	//
+	// The check and the save must be atomic.
+	Create(s *Session) error
+	// Link links the fingerprint to the session with the given id, returning the updated session, or nil if there is none.
+	//
+	// If the fingerprint is linked to a session already, it fails with ErrCertLinked.
+	// The check and the change must be atomic.
+	Link(id, fingerprint string) (*Session, error)
+	// Unlink unlinks the fingerprint from the session with the given id, returning the updated session, or nil if there is none.
+	//
+	// It fails with ErrNotLinked if the fingerprint is not linked to that session, and with ErrLastCert if it is its only one.
+	// The check and the change must be atomic.
+	Unlink(id, fingerprint string) (*Session, error)
+	// Delete deletes the session with the given id, if it exists.
+	Delete(id string) error
+}
+
+// MemorySessionStore is a SessionStore that keeps sessions in memory
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*Session // by id
+	certs    map[string]string   // fingerprint to id
+}
+
+// NewMemorySessionStore creates an empty MemorySessionStore
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]*Session), certs: make(map[string]string)}
+}
+
+// Get implements SessionStore
+func (m *MemorySessionStore) Get(id string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.sessions[id]; ok {
+		return s.clone(), nil
+	}
+	return nil, nil
+}
+
+// Lookup implements SessionStore
+func (m *MemorySessionStore) Lookup(fingerprint string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.sessions[m.certs[fingerprint]]; ok {
+		return s.clone(), nil
+	}
+	return nil, nil
+}
+
+// Save implements SessionStore
+func (m *MemorySessionStore) Save(s *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.save(s)
+}
+
+// Create implements SessionStore
+func (m *MemorySessionStore) Create(s *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.create(s)
+}
+
+// Link implements SessionStore
+func (m *MemorySessionStore) Link(id, fingerprint string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, err := m.link(id, fingerprint)
+	if err != nil || s == nil {
+		return nil, err
+	}
+	return s.clone(), nil
+}
+
+// Unlink implements SessionStore
+func (m *MemorySessionStore) Unlink(id, fingerprint string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, err := m.unlink(id, fingerprint)
+	if err != nil || s == nil {
+		return nil, err
+	}
+	return s.clone(), nil
+}
+
+// Delete implements SessionStore
+func (m *MemorySessionStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.delete(id)
+	return nil
+}
+
+// put stores s as-is, its certificates must not be linked to another session
+func (m *MemorySessionStore) put(s *Session) {
+	m.sessions[s.ID] = s
+	for _, v := range s.Certs {
+		m.certs[v] = s.ID
+	}
+}
+
+func (m *MemorySessionStore) save(s *Session) error {
+	stored, ok := m.sessions[s.ID]
+	if !ok {
+		return ErrNoSession
+	}
+	c := s.clone() // for the Data
+	stored.Data, stored.Expires = c.Data, c.Expires
+	return nil
+}
+
+func (m *MemorySessionStore) create(s *Session) error {
+	for _, v := range s.Certs {
+		if _, ok := m.certs[v]; ok {
+			return ErrCertLinked
+		}
+	}
+	m.put(s.clone())
+	return nil
+}
+
+func (m *MemorySessionStore) link(id, fingerprint string) (*Session, error) {
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	if _, ok := m.certs[fingerprint]; ok {
+		return nil, ErrCertLinked
+	}
+	s.Certs = append(s.Certs, fingerprint)
+	m.certs[fingerprint] = id
+	return s, nil
+}
+
+func (m *MemorySessionStore) unlink(id, fingerprint string) (*Session, error) {
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	if m.certs[fingerprint] != id {
+		return nil, ErrNotLinked
+	}
+	if len(s.Certs) == 1 {
+		return nil, ErrLastCert
+	}
+	s.Certs = without(s.Certs, fingerprint)
+	delete(m.certs, fingerprint)
+	return s, nil
+}
+
+// restore puts the session with the given id back to old, a clone taken before a change, or deletes it if old is nil
+func (m *MemorySessionStore) restore(id string, old *Session) {
+	m.delete(id)
+	if old != nil {
+		m.put(old)
+	}
+}
+
+// snapshot returns a clone of the session with the given id, or nil, see restore
+func (m *MemorySessionStore) snapshot(id string) *Session {
+	if s, ok := m.sessions[id]; ok {
+		return s.clone()
+	}
+	return nil
+}
+
+func (m *MemorySessionStore) delete(id string) {
+	if s, ok := m.sessions[id]; ok {
+		for _, v := range s.Certs {
+			delete(m.certs, v)
+		}
+		delete(m.sessions, id)
+	}
+}
+
+func without(list []string, s string) []string {
+	out := list[:0]
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// FileSessionStore is a SessionStore backed by a JSON file
+//
+// Sessions are cached in memory, and the whole file is rewritten on every change.
+// Changes that could not be written are undone in memory too.
+type FileSessionStore struct {
+	path string
+	mem  *MemorySessionStore
+}
+
+// NewFileSessionStore creates a FileSessionStore backed by the file at path, loading it if it exists
+func NewFileSessionStore(path string) (*FileSessionStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f := &FileSessionStore{path: path, mem: NewMemorySessionStore()}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return f, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var sessions []*Session
+	if err := json.Unmarshal(b, &sessions); err != nil {
+		return nil, err
+	}
+	for _, v := range sessions {
+		f.mem.put(v)
+	}
+	return f, nil
+}
+
+// Get implements SessionStore
+func (f *FileSessionStore) Get(id string) (*Session, error) {
+	return f.mem.Get(id)
+}
+
+// Lookup implements SessionStore
+func (f *FileSessionStore) Lookup(fingerprint string) (*Session, error) {
+	return f.mem.Lookup(fingerprint)
+}
+
+// Save implements SessionStore
+func (f *FileSessionStore) Save(s *Session) error {
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+	old := f.mem.snapshot(s.ID)
+	if err := f.mem.save(s); err != nil {
+		return err
+	}
+	return f.commit(s.ID, old)
+}
+
+// Create implements SessionStore
+func (f *FileSessionStore) Create(s *Session) error {
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+	if err := f.mem.create(s); err != nil {
+		return err
+	}
+	return f.commit(s.ID, nil)
+}
+
+// Link implements SessionStore
+func (f *FileSessionStore) Link(id, fingerprint string) (*Session, error) {
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+	old := f.mem.snapshot(id)
+	s, err := f.mem.link(id, fingerprint)
+	if err != nil || s == nil {
+		return nil, err
+	}
+	if err := f.commit(id, old); err != nil {
+		return nil, err
+	}
+	return s.clone(), nil
+}
+
+// Unlink implements SessionStore
+func (f *FileSessionStore) Unlink(id, fingerprint string) (*Session, error) {
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+	old := f.mem.snapshot(id)
+	s, err := f.mem.unlink(id, fingerprint)
+	if err != nil || s == nil {
+		return nil, err
+	}
+	if err := f.commit(id, old); err != nil {
+		return nil, err
+	}
+	return s.clone(), nil
+}
+
+// Delete implements SessionStore
+func (f *FileSessionStore) Delete(id string) error {
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+	old := f.mem.snapshot(id)
+	f.mem.delete(id)
+	return f.commit(id, old)
+}
+
+// commit writes the sessions, restoring the session with the given id to old if that fails
+func (f *FileSessionStore) commit(id string, old *Session) error {
+	err := f.write()
+	if err != nil {
+		f.mem.restore(id, old)
+	}
+	return err
+}
+
+// write saves the sessions, atomically replacing the file
+func (f *FileSessionStore) write() error {
+	sessions := make([]*Session, 0, len(f.mem.sessions))
+	for _, v := range f.mem.sessions {
+		sessions = append(sessions, v)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+	b, err := json.Marshal(sessions)
+	if err != nil {
+		return err
+	}
+	tmp := f.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
@@ -0,0 +1,56 @@
+package gms
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// errHandlerTimeout is returned to handlers writing after their timeout
+const errHandlerTimeout = gmsError("handler timed out")
+
+// timeoutWriter guards the connection of a handler that may time out
+//
+// Once timed out, writes fail, so that a late handler can't interleave with the timeout response.
+// The lock only guards the flags: a write blocked on a client that doesn't read must not block the timeout.
+type timeoutWriter struct {
+	c net.Conn
+
+	mu       sync.Mutex
+	wrote    bool
+	timedOut bool
+}
+
+func (t *timeoutWriter) Write(b []byte) (int, error) {
+	t.mu.Lock()
+	if t.timedOut {
+		t.mu.Unlock()
+		return 0, errHandlerTimeout
+	}
+	t.wrote = true
+	t.mu.Unlock()
+	return t.c.Write(b)
+}
+
+// timeout makes further writes fail, and sends header unless something was written already
+//
+// Otherwise, writes in flight are interrupted.
+func (t *timeoutWriter) timeout(header string) {
+	t.mu.Lock()
+	t.timedOut = true
+	wrote := t.wrote
+	t.mu.Unlock()
+	if wrote {
+		t.c.SetWriteDeadline(time.Now())
+		return
+	}
+	io.WriteString(t.c, header) // nothing else can be writing
+}
+
+// isTimeout returns true if err is a network timeout
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
@@ -0,0 +1,165 @@
+package gms_test
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/gms"
+)
+
+// chanLogger sends log lines to a channel, dropping them if it's full
+type chanLogger chan string
+
+func (l chanLogger) Printf(format string, args ...interface{}) {
+	select {
+	case l <- fmt.Sprintf(format, args...):
+	default:
+	}
+}
+
+// expectLog waits for a log line containing s
+func expectLog(t *testing.T, l chanLogger, s string) {
+	t.Helper()
+	timer := time.NewTimer(5 * time.Second)
+	defer timer.Stop()
+	for {
+		select {
+		case v := <-l:
+			if strings.Contains(v, s) {
+				return
+			}
+		case <-timer.C:
+			t.Fatalf("expected a log line containing %q", s)
+		}
+	}
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	l := make(chanLogger, 10)
+	addr, _ := testServer(t, &gms.Server{Logger: l, HandshakeTimeout: 50 * time.Millisecond})
+
+	con, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+	expectLog(t, l, "timeout during tls handshake")
+}
+
+func TestReadTimeout(t *testing.T) {
+	l := make(chanLogger, 10)
+	addr, _ := testServer(t, &gms.Server{Logger: l, ReadTimeout: 50 * time.Millisecond})
+
+	con, err := tls.Dial("tcp", addr, &tls.Config{ServerName: "localhost", InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+	expectLog(t, l, "timeout reading request")
+	con.SetDeadline(time.Now().Add(5 * time.Second))
+	if b, err := ioutil.ReadAll(con); err != nil || len(b) != 0 {
+		t.Errorf("expected the connection to be closed, instead found %q %v", b, err)
+	}
+}
+
+func TestHandlerTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	addr, _ := testServer(t, &gms.Server{HandlerTimeout: 50 * time.Millisecond, Handler: gms.HandlerFunc(func(ctx *gemini.Ctx) {
+		<-release
+	})})
+
+	if b, err := request(addr, "gemini://localhost/"); err != nil || b != "40 handler timed out\r\n" {
+		t.Errorf("got %q %v", b, err)
+	}
+}
+
+func TestHandlerTimeoutStreaming(t *testing.T) {
+	l := make(chanLogger, 10)
+	stopped := make(chan error, 1)
+	addr, _ := testServer(t, &gms.Server{Logger: l, HandlerTimeout: 200 * time.Millisecond, Handler: gms.HandlerFunc(func(ctx *gemini.Ctx) {
+		ctx.Res.Status = gemini.StatusSuccess
+		ctx.Res.SetMeta("application/octet-stream")
+		ctx.Res.Commit()
+		chunk := make([]byte, 64<<10)
+		for {
+			if _, err := ctx.Res.Write(chunk); err != nil {
+				stopped <- err
+				return
+			}
+			ctx.Res.Flush() // errors show up in the next Write
+		}
+	})})
+
+	con, err := tls.Dial("tcp", addr, &tls.Config{ServerName: "localhost", InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+	con.Write([]byte("gemini://localhost/\r\n")) // and never read
+	expectLog(t, l, "handler timed out")
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Error("expected the handler's blocked write to fail")
+	}
+}
+
+func TestWriteTimeout(t *testing.T) {
+	l := make(chanLogger, 10)
+	addr, _ := testServer(t, &gms.Server{Logger: l, WriteTimeout: 50 * time.Millisecond, Handler: gms.HandlerFunc(func(ctx *gemini.Ctx) {
+		ctx.Res.Status = gemini.StatusSuccess
+		ctx.Res.SetMeta("application/octet-stream")
+		ctx.Res.Write(make([]byte, 64<<20)) // more than the socket buffers hold
+	})})
+
+	con, err := tls.Dial("tcp", addr, &tls.Config{ServerName: "localhost", InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+	con.Write([]byte("gemini://localhost/\r\n"))
+	expectLog(t, l, "timeout writing response") // without reading anything
+}
+
+func TestBadResponses(t *testing.T) {
+	addr, _ := testServer(t, &gms.Server{Handler: gms.HandlerFunc(func(ctx *gemini.Ctx) {
+		switch ctx.Req.URL.Path {
+		case "/panic":
+			panic("oops")
+		case "/committed":
+			ctx.Res.Status = gemini.StatusSuccess
+			ctx.Res.SetMeta("text/plain")
+			ctx.Res.Commit()
+			ctx.Res.WriteString("partial")
+			ctx.Res.Flush()
+			panic("oops")
+		case "/status":
+			ctx.Res.Status = 7
+		}
+	})})
+
+	for _, tc := range []struct {
+		path, want string
+	}{
+		{"/panic", "40 internal error\r\n"},
+		{"/committed", "20 text/plain\r\npartial"},
+		{"/status", "42 invalid response status\r\n"},
+		{"/nothing", "42 invalid response status\r\n"},
+	} {
+		if b, err := request(addr, "gemini://localhost"+tc.path); err != nil || b != tc.want {
+			t.Errorf("%s: got %q %v, want %q", tc.path, b, err, tc.want)
+		}
+	}
+
+	// unparseable, without a more specific reason
+	if b, err := request(addr, "gemini://%zz/"); err != nil || b != "59 bad request\r\n" {
+		t.Errorf("bad url: got %q %v", b, err)
+	}
+}
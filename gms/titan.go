@@ -0,0 +1,54 @@
+package gms
+
+import (
+	"io"
+
+	"toast.cafe/x/gemini"
+)
+
+// A TitanHandler responds to a titan upload request.
+//
+// ServeTitan gets the parsed request and a reader over the uploaded body, limited to the advertised size.
+// It should consume the body and write the reply into Ctx.Res like a Handler, usually redirecting to the uploaded resource.
+type TitanHandler interface {
+	ServeTitan(*gemini.Ctx, *gemini.TitanRequest, io.Reader)
+}
+
+// TitanHandlerFunc is an adapter that allows using standalone functions as TitanHandlers
+type TitanHandlerFunc func(*gemini.Ctx, *gemini.TitanRequest, io.Reader)
+
+// ServeTitan calls f(ctx, req, body)
+func (f TitanHandlerFunc) ServeTitan(ctx *gemini.Ctx, req *gemini.TitanRequest, body io.Reader) {
+	f(ctx, req, body)
+}
+
+// Titan generates a Handler that passes titan uploads to titan, and everything else to next
+//
+// The result is a regular Handler, so it can be registered in any Mux alongside gemini handlers.
+// If next is nil, non-titan requests are refused.
+func Titan(titan TitanHandler, next Handler) HandlerFunc {
+	return func(ctx *gemini.Ctx) {
+		if ctx.Titan != nil {
+			titan.ServeTitan(ctx, ctx.Titan, ctx.Body)
+			return
+		}
+		if next == nil {
+			ctx.Res.Status = gemini.StatusBadRequest
+			ctx.Res.SetMeta("only titan uploads are accepted here")
+			return
+		}
+		next.ServeGem(ctx)
+	}
+}
+
+// GeminiOnly generates a Handler that refuses titan uploads, passing everything else to next
+func GeminiOnly(next Handler) HandlerFunc {
+	return func(ctx *gemini.Ctx) {
+		if ctx.Titan != nil {
+			ctx.Res.Status = gemini.StatusBadRequest
+			ctx.Res.SetMeta("uploads are not accepted here")
+			return
+		}
+		next.ServeGem(ctx)
+	}
+}
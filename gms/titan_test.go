@@ -0,0 +1,50 @@
+package gms_test
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/gms"
+)
+
+func TestTitan(t *testing.T) {
+	upload := gms.TitanHandlerFunc(func(ctx *gemini.Ctx, tr *gemini.TitanRequest, body io.Reader) {
+		b, err := ioutil.ReadAll(body)
+		if err != nil {
+			ctx.Res.Status = gemini.StatusTemporaryFailure
+			ctx.Res.SetMeta(err.Error())
+			return
+		}
+		ctx.Res.Status = gemini.StatusSuccess
+		ctx.Res.SetMeta("text/plain")
+		ctx.Res.WriteString(tr.Mime + " " + string(b))
+	})
+	mux := gms.PathMux(gms.HandlerFunc(func(ctx *gemini.Ctx) {
+		ctx.Res.Status = gemini.StatusNotFound
+		ctx.Res.SetMeta(ctx.Req.Path())
+	}))
+	mux.Register("/up", gms.Titan(upload, nil))
+
+	addr, _ := testServer(t, &gms.Server{Handler: mux})
+	if b, err := request(addr, "titan://localhost/up;size=5\r\nhello"); err != nil || b != "59 titan uploads are not accepted\r\n" {
+		t.Errorf("titan disabled: got %q %v", b, err)
+	}
+
+	addr, _ = testServer(t, &gms.Server{Handler: mux, Titan: true, TitanMaxSize: 8})
+	for _, tc := range []struct {
+		req, want string
+	}{
+		// the path is muxed without the titan parameters, and the body is cut at the advertised size
+		{"titan://localhost/up;size=5;mime=text/plain\r\nhello world", "20 text/plain\r\ntext/plain hello"},
+		{"titan://localhost/up;size=0", "20 text/plain\r\n" + gemini.TitanMime + " "},
+		{"titan://localhost/up;size=9\r\nhello world", "59 titan upload is too large\r\n"},
+		{"titan://localhost/other;size=5\r\nhello", "51 /other\r\n"},
+		{"gemini://localhost/up", "59 only titan uploads are accepted here\r\n"},
+	} {
+		if b, err := request(addr, tc.req); err != nil || b != tc.want {
+			t.Errorf("%q: got %q %v, want %q", tc.req, b, err, tc.want)
+		}
+	}
+}
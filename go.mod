@@ -1,3 +1,8 @@
 module toast.cafe/x/gemini
 
 go 1.14
+
+require (
+	golang.org/x/net v0.11.0
+	golang.org/x/text v0.13.0
+)
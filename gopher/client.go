@@ -0,0 +1,91 @@
+package gopher
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+)
+
+// DefaultMaxSize is the largest response a Client reads by default
+const DefaultMaxSize = 16 << 20
+
+// Client is a gopher client
+type Client struct {
+	Dialer net.Dialer
+
+	// MaxSize is the largest response read, DefaultMaxSize if zero.
+	MaxSize int64
+}
+
+// DefaultClient is the default
+var DefaultClient = &Client{}
+
+// Response is a gopher response
+//
+// Gopher responses have no header, what they contain depends entirely on the type of the request.
+type Response struct {
+	Request *Request
+	Body    []byte
+}
+
+// Do performs the request, reading the whole response
+func (c *Client) Do(req *Request) (*Response, error) {
+	con, err := c.Dialer.Dial("tcp", req.addr())
+	if err != nil {
+		return nil, err
+	}
+	defer con.Close()
+
+	line := req.Selector
+	if req.Search != "" {
+		line += "\t" + req.Search
+	}
+	if _, err := fmt.Fprintf(con, "%s\r\n", line); err != nil {
+		return nil, err
+	}
+
+	max := c.MaxSize
+	if max <= 0 {
+		max = DefaultMaxSize
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(con, max))
+	if err != nil {
+		return nil, err
+	}
+	return &Response{Request: req, Body: body}, nil
+}
+
+// Fetch parses the gopher URL and performs the request
+func (c *Client) Fetch(u string) (*Response, error) {
+	req, err := ParseURL(u)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// Menu parses the response as a menu
+func (r *Response) Menu() ([]*Item, error) {
+	return ParseMenu(bytes.NewReader(r.Body))
+}
+
+// Text returns the response as text, dropping the "." terminator and undoing dot-stuffing
+func (r *Response) Text() string {
+	lines := strings.Split(strings.ReplaceAll(string(r.Body), "\r\n", "\n"), "\n")
+	for i, v := range lines {
+		if v == "." {
+			lines = lines[:i]
+			break
+		}
+		if strings.HasPrefix(v, "..") {
+			lines[i] = v[1:]
+		}
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,143 @@
+package gopher
+
+import (
+	"errors"
+	"net/url"
+	"path"
+	"strings"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/gemtext"
+	"toast.cafe/x/gemini/gms"
+)
+
+// MenuDocument turns a menu into gemtext
+//
+// Info and error items become text lines, everything else becomes a link to Item.URL.
+// If link is not nil, it is used to compute link targets instead.
+func MenuDocument(menu []*Item, link func(*Item) string) gemtext.Document {
+	if link == nil {
+		link = (*Item).URL
+	}
+	var out gemtext.Document
+	for _, v := range menu {
+		switch v.Type {
+		case ItemInfo, ItemError:
+			out = append(out, gemtext.Text(v.Display))
+		default:
+			out = append(out, &gemtext.Link{URL: link(v), Label: v.Display})
+		}
+	}
+	return out
+}
+
+// TextDocument turns a text file into gemtext, as a single preformatted block
+func TextDocument(text, alt string) gemtext.Document {
+	return gemtext.Document{&gemtext.Preformatted{Alt: alt, Lines: strings.Split(text, "\n")}}
+}
+
+// Handler returns a Handler that serves the gopher hole at upstream as gemtext
+//
+// Request paths follow the gopher URL layout, "/1/selector" being the menu at "/selector".
+// Menus become link lines, with links back into upstream rewritten to stay within the gateway.
+// Text files become preformatted blocks, other content is passed through as-is.
+// If client is nil, DefaultClient is used.
+func Handler(upstream string, client *Client) (gms.HandlerFunc, error) {
+	base, err := ParseURL(upstream)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = DefaultClient
+	}
+	link := func(i *Item) string {
+		if i.addr() != base.addr() || i.Type == ItemHTML || i.Type == ItemTelnet {
+			return i.URL()
+		}
+		return (&url.URL{Path: "/" + string(i.Type) + i.Selector}).String()
+	}
+
+	return func(ctx *gemini.Ctx) {
+		req := &Request{Host: base.Host, Port: base.Port, Type: ItemMenu}
+		if p := ctx.Req.URL.Path; len(p) > 1 {
+			req.Type = ItemType(p[1])
+			req.Selector = p[2:]
+		}
+		serve(ctx, client, req, link)
+	}, nil
+}
+
+// ProxyHandler returns a Handler that serves gopher:// proxy requests as gemtext
+//
+// Unlike Handler, links are left pointing to gopher, for the gemini client to proxy in turn.
+// Requests for other schemes are refused.
+// If client is nil, DefaultClient is used.
+//
+// Loopback and private addresses are refused too, see gms.PublicAddressesOnly, unless the client's dialer has its own Control function.
+// Only set one if the handler is not reachable by untrusted users, or as an allow list: an open proxy exposes the server's network.
+func ProxyHandler(client *Client) gms.HandlerFunc {
+	if client == nil {
+		client = DefaultClient
+	}
+	c := *client
+	c.Dialer = gms.ProxyDialer(c.Dialer)
+	client = &c
+	return func(ctx *gemini.Ctx) {
+		if ctx.Req.URL.Scheme != "gopher" {
+			ctx.Res.Status = gemini.StatusProxyRequestRefused
+			ctx.Res.SetMeta("only gopher is proxied")
+			return
+		}
+		u := *ctx.Req.URL
+		u.RawQuery = "" // that's the gemini input
+		req, err := ParseURL(u.String())
+		if err != nil {
+			ctx.Res.Status = gemini.StatusBadRequest
+			ctx.Res.SetMeta(err.Error())
+			return
+		}
+		serve(ctx, client, req, nil)
+	}
+}
+
+// serve performs req on behalf of ctx, and converts the result
+func serve(ctx *gemini.Ctx, client *Client, req *Request, link func(*Item) string) {
+	if req.Type == ItemSearch {
+		if !ctx.Req.HasInput() {
+			ctx.Res.Status = gemini.StatusInput
+			ctx.Res.SetMeta("search")
+			return
+		}
+		req.Search, _ = ctx.Req.Input()
+	}
+
+	res, err := client.Do(req)
+	if errors.Is(err, gms.ErrForbiddenAddress) {
+		ctx.Res.Status = gemini.StatusProxyRequestRefused
+		ctx.Res.SetMeta("refusing to proxy to a non-public address")
+		return
+	} else if err != nil {
+		ctx.Res.Status = gemini.StatusProxyError
+		ctx.Res.SetMeta("upstream request failed")
+		return
+	}
+
+	ctx.Res.Status = gemini.StatusSuccess
+	switch req.Type {
+	case ItemMenu, ItemSearch:
+		menu, err := res.Menu()
+		if err != nil {
+			ctx.Res.Status = gemini.StatusProxyError
+			ctx.Res.SetMeta("could not parse upstream menu")
+			return
+		}
+		ctx.Res.SetMeta(gemtext.MediaType + "; charset=utf-8")
+		gemtext.NewWriter(ctx.Res).Document(MenuDocument(menu, link))
+	case ItemText:
+		ctx.Res.SetMeta(gemtext.MediaType + "; charset=utf-8")
+		gemtext.NewWriter(ctx.Res).Document(TextDocument(res.Text(), path.Base(req.Selector)))
+	default:
+		ctx.Res.SetMeta(req.Type.MediaType(req.Selector))
+		ctx.Res.Write(res.Body)
+	}
+}
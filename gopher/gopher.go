@@ -0,0 +1,217 @@
+// Package gopher implements a gopher (RFC 1436) client, a gemini gateway to gopher, and a gopher server for gms handlers.
+package gopher
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+type gopherError string
+
+func (e gopherError) Error() string { return string(e) }
+
+// Gopher errors
+const (
+	ErrMenu = gopherError("invalid gopher menu")
+	ErrURL  = gopherError("invalid gopher url")
+)
+
+// DefaultPort is the default gopher port
+const DefaultPort = 70
+
+// ItemType is the type of a gopher item, the first character of a menu line
+type ItemType byte
+
+// Gopher item types
+const (
+	ItemText      ItemType = '0'
+	ItemMenu      ItemType = '1'
+	ItemCSO       ItemType = '2'
+	ItemError     ItemType = '3'
+	ItemBinHex    ItemType = '4'
+	ItemDOS       ItemType = '5'
+	ItemUUEncoded ItemType = '6'
+	ItemSearch    ItemType = '7'
+	ItemTelnet    ItemType = '8'
+	ItemBinary    ItemType = '9'
+	ItemMirror    ItemType = '+'
+	ItemGIF       ItemType = 'g'
+	ItemImage     ItemType = 'I'
+	ItemTN3270    ItemType = 'T'
+	ItemDocument  ItemType = 'd'
+	ItemHTML      ItemType = 'h'
+	ItemInfo      ItemType = 'i'
+	ItemSound     ItemType = 's'
+)
+
+// IsMenu returns true if items of this type are fetched as menus
+func (t ItemType) IsMenu() bool { return t == ItemMenu || t == ItemSearch }
+
+// MediaType guesses the media type of an item of this type with the given selector
+//
+// Menus are reported as text/gemini, since that's what the gateway turns them into.
+func (t ItemType) MediaType(selector string) string {
+	switch t {
+	case ItemMenu, ItemSearch:
+		return "text/gemini"
+	case ItemText:
+		return "text/plain"
+	case ItemGIF:
+		return "image/gif"
+	case ItemHTML:
+		return "text/html"
+	}
+	if mt := mime.TypeByExtension(path.Ext(selector)); mt != "" {
+		return mt
+	}
+	return "application/octet-stream"
+}
+
+// Item is a single gopher menu entry
+type Item struct {
+	Type     ItemType
+	Display  string
+	Selector string
+	Host     string
+	Port     int
+}
+
+// ParseItem parses a single menu line, without the line terminator
+//
+// Missing trailing fields are tolerated, as plenty of servers omit them for info lines.
+func ParseItem(line string) (*Item, error) {
+	if line == "" {
+		return nil, fmt.Errorf("%w: empty line", ErrMenu)
+	}
+	fields := strings.Split(line[1:], "\t")
+	for len(fields) < 4 {
+		fields = append(fields, "")
+	}
+	i := &Item{Type: ItemType(line[0]), Display: fields[0], Selector: fields[1], Host: fields[2]}
+	if fields[3] != "" {
+		port, err := strconv.Atoi(strings.TrimSpace(fields[3]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid port %q", ErrMenu, fields[3])
+		}
+		i.Port = port
+	}
+	return i, nil
+}
+
+// String returns the item as a menu line, without the line terminator
+func (i *Item) String() string {
+	clean := strings.NewReplacer("\t", " ", "\r", "", "\n", " ")
+	return fmt.Sprintf("%c%s\t%s\t%s\t%d", i.Type, clean.Replace(i.Display), clean.Replace(i.Selector), i.Host, i.Port)
+}
+
+// URL returns the location the item points to
+//
+// This is a gopher URL, except for telnet items and "URL:" html items.
+func (i *Item) URL() string {
+	switch {
+	case i.Type == ItemHTML && strings.HasPrefix(i.Selector, "URL:"):
+		return strings.TrimPrefix(i.Selector, "URL:")
+	case i.Type == ItemTelnet || i.Type == ItemTN3270:
+		return (&url.URL{Scheme: "telnet", Host: i.addr()}).String()
+	}
+	return (&Request{Host: i.Host, Port: i.Port, Type: i.Type, Selector: i.Selector}).String()
+}
+
+func (i *Item) addr() string {
+	port := i.Port
+	if port == 0 {
+		port = DefaultPort
+	}
+	return net.JoinHostPort(i.Host, strconv.Itoa(port))
+}
+
+// ParseMenu reads a menu until the "." terminator or the end of r
+//
+// Empty lines are skipped.
+func ParseMenu(r io.Reader) ([]*Item, error) {
+	var out []*Item
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		if line == "." {
+			break
+		}
+		if line == "" {
+			continue
+		}
+		i, err := ParseItem(line)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, i)
+	}
+	return out, sc.Err()
+}
+
+// Request is a gopher request, the equivalent of a gopher URL (RFC 4266)
+type Request struct {
+	Host     string
+	Port     int
+	Type     ItemType
+	Selector string
+	Search   string // sent after a tab, for search items
+}
+
+// ParseURL parses a gopher URL into a Request
+//
+// An empty path is the root menu.
+func ParseURL(s string) (*Request, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrURL, err)
+	}
+	if u.Scheme != "gopher" || u.Hostname() == "" {
+		return nil, fmt.Errorf("%w: expected an absolute gopher url", ErrURL)
+	}
+	r := &Request{Host: u.Hostname(), Port: DefaultPort, Type: ItemMenu}
+	if p := u.Port(); p != "" {
+		if r.Port, err = strconv.Atoi(p); err != nil {
+			return nil, fmt.Errorf("%w: invalid port", ErrURL)
+		}
+	}
+	p := u.Path
+	if u.RawQuery != "" || u.ForceQuery { // gopher has no query, it's part of the selector
+		q, err := url.PathUnescape(u.RawQuery)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrURL, err)
+		}
+		p += "?" + q
+	}
+	if len(p) > 1 {
+		r.Type = ItemType(p[1])
+		r.Selector = p[2:]
+	}
+	if i := strings.IndexByte(r.Selector, '\t'); i >= 0 {
+		r.Selector, r.Search = r.Selector[:i], r.Selector[i+1:]
+	}
+	return r, nil
+}
+
+// String returns the request as a gopher URL
+func (r *Request) String() string {
+	p := "/" + string(r.Type) + r.Selector
+	if r.Search != "" {
+		p += "\t" + r.Search
+	}
+	u := url.URL{Scheme: "gopher", Host: r.Host, Path: p}
+	if r.Port != 0 && r.Port != DefaultPort {
+		u.Host = net.JoinHostPort(r.Host, strconv.Itoa(r.Port))
+	}
+	return u.String()
+}
+
+func (r *Request) addr() string {
+	return (&Item{Host: r.Host, Port: r.Port}).addr()
+}
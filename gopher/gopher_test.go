@@ -0,0 +1,172 @@
+package gopher_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/gms"
+	"toast.cafe/x/gemini/gopher"
+)
+
+func TestParseURL(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want gopher.Request
+	}{
+		{"gopher://example.org", gopher.Request{Host: "example.org", Port: 70, Type: '1'}},
+		{"gopher://example.org/", gopher.Request{Host: "example.org", Port: 70, Type: '1'}},
+		{"gopher://example.org:7070/0/notes.txt", gopher.Request{Host: "example.org", Port: 7070, Type: '0', Selector: "/notes.txt"}},
+		{"gopher://example.org/7/search%09cats", gopher.Request{Host: "example.org", Port: 70, Type: '7', Selector: "/search", Search: "cats"}},
+		{"gopher://example.org/1/a?b", gopher.Request{Host: "example.org", Port: 70, Type: '1', Selector: "/a?b"}},
+		{"gopher://example.org/0/a%20b?c%20d", gopher.Request{Host: "example.org", Port: 70, Type: '0', Selector: "/a b?c d"}},
+	} {
+		got, err := gopher.ParseURL(tc.in)
+		if err != nil {
+			t.Errorf("%s: %s", tc.in, err)
+			continue
+		}
+		if *got != tc.want {
+			t.Errorf("%s: got %+v, want %+v", tc.in, *got, tc.want)
+		}
+	}
+	if _, err := gopher.ParseURL("gemini://example.org/"); err == nil {
+		t.Error("expected an error for a gemini url")
+	}
+}
+
+func TestParseMenu(t *testing.T) {
+	menu, err := gopher.ParseMenu(strings.NewReader("" +
+		"iWelcome\t\terror.host\t1\r\n" +
+		"1Phlog\t/phlog\texample.org\t70\r\n" +
+		"0About\t/about.txt\tother.org\t7070\r\n" +
+		"hWeb\tURL:https://example.org/\texample.org\t70\r\n" +
+		"iNo fields\r\n" +
+		".\r\n" +
+		"1Ignored\t/\texample.org\t70\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(menu) != 5 {
+		t.Fatalf("got %d items, want 5", len(menu))
+	}
+	want := []string{
+		"gopher://error.host:1/iWelcome",
+		"gopher://example.org/1/phlog",
+		"gopher://other.org:7070/0/about.txt",
+		"https://example.org/",
+	}
+	for i, w := range want {
+		if menu[i].Type != gopher.ItemInfo {
+			if got := menu[i].URL(); got != w {
+				t.Errorf("item %d: got %s, want %s", i, got, w)
+			}
+		}
+	}
+	if menu[4].Display != "No fields" {
+		t.Errorf("got %q", menu[4].Display)
+	}
+
+	doc := gopher.MenuDocument(menu, nil).String()
+	if want := "Welcome\n=> gopher://example.org/1/phlog Phlog\n=> gopher://other.org:7070/0/about.txt About\n=> https://example.org/ Web\nNo fields\n"; doc != want {
+		t.Errorf("got %q, want %q", doc, want)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen:", err)
+	}
+	defer l.Close()
+	srv := &gopher.Server{Handler: gmsHandler()}
+	go srv.ServeListener(l)
+
+	gw, err := gopher.Handler("gopher://"+l.Addr().String()+"/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(path, query string) *gemini.Ctx {
+		t.Helper()
+		ctx, err := gemini.NewRequestCtx("gemini://proxy.example" + path + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx.Res, _ = gemini.NewResponse(gemini.StatusSuccess, "")
+		gw(ctx)
+		ctx.Res.Flush()
+		return ctx
+	}
+
+	ctx := get("/", "")
+	body, _ := ctx.Res.Body()
+	if want := " # Home\n=> /1/sub/ Sub\n=> /0/notes.txt notes.txt\n=> /1/search Search\n=> https://example.org/ Web\n"; body != want {
+		t.Errorf("menu: got %q, want %q", body, want)
+	}
+
+	ctx = get("/0/notes.txt", "")
+	body, _ = ctx.Res.Body()
+	if want := "```notes.txt\nline one\n.hidden\n```\n"; body != want {
+		t.Errorf("text: got %q, want %q", body, want)
+	}
+
+	ctx = get("/0/my%20notes.txt", "")
+	body, _ = ctx.Res.Body()
+	if want := "```my notes.txt\nmine\n```\n"; body != want {
+		t.Errorf("escaped text: got %q, want %q", body, want)
+	}
+
+	ctx = get("/7/search", "")
+	if ctx.Status() != gemini.StatusInput {
+		t.Errorf("search: got status %d", ctx.Status())
+	}
+	ctx = get("/7/search", "?cats%20dogs")
+	body, _ = ctx.Res.Body()
+	if want := "results for cats dogs\n"; body != want {
+		t.Errorf("search: got %q, want %q", body, want)
+	}
+
+	ctx = get("/1/missing", "")
+	body, _ = ctx.Res.Body()
+	if want := "not here\n"; body != want {
+		t.Errorf("error: got %q, want %q", body, want)
+	}
+}
+
+func gmsHandler() gms.HandlerFunc {
+	return func(ctx *gemini.Ctx) {
+		switch ctx.Req.Path() {
+		case "/":
+			ctx.Res.WriteString("# Home\n=> sub/ Sub\n=> notes.txt\n=> /search Search\n=> https://example.org/ Web\n")
+		case "/notes.txt":
+			ctx.Res.SetMeta("text/plain")
+			ctx.Res.WriteString("line one\n..hidden\n.\n")
+		case "/my%20notes.txt":
+			ctx.Res.SetMeta("text/plain")
+			ctx.Res.WriteString("mine\n")
+		case "/search":
+			if !ctx.Req.HasInput() {
+				ctx.Res.Status = gemini.StatusInput
+				ctx.Res.SetMeta("query")
+				return
+			}
+			q, _ := ctx.Req.Input()
+			ctx.Res.WriteString("results for " + q + "\n")
+		default:
+			ctx.Res.Status = gemini.StatusNotFound
+			ctx.Res.SetMeta("not here")
+		}
+	}
+}
+
+func TestProxyRefusesPrivate(t *testing.T) {
+	for _, v := range []string{"gopher://127.0.0.1:7070/", "gopher://[::1]/", "gopher://192.168.1.1/", "gopher://10.0.0.1/0/x"} {
+		ctx, _ := gemini.NewRequestCtx(v)
+		ctx.Res = new(gemini.Response)
+		gopher.ProxyHandler(nil)(ctx)
+		if ctx.Status() != gemini.StatusProxyRequestRefused {
+			t.Errorf("%s: got status %d, want %d", v, ctx.Status(), gemini.StatusProxyRequestRefused)
+		}
+	}
+}
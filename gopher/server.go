@@ -0,0 +1,213 @@
+package gopher
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/gemtext"
+	"toast.cafe/x/gemini/gms"
+)
+
+// Server is a gopher server serving gms handlers
+//
+// Requests reach the handler as a gemini.Ctx with a gopher:// request, the selector being the path.
+// Searches are exposed as the request input, see gemini.Request.Input.
+// Gemtext responses are served as menus, other successful responses as-is, and everything else as a short menu.
+type Server struct {
+	// TCP address to listen on, defaults to :70
+	Addr    string
+	Handler gms.Handler
+	Logger  gms.Logger
+
+	// Host and Port are used in menus to refer to this server, they default to the address of the connection.
+	Host string
+	Port int
+}
+
+func (s *Server) log(fmt string, args ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Printf(fmt, args...)
+	}
+}
+
+// Serve listens on Addr and serves requests
+func (s *Server) Serve() error {
+	addr := s.Addr
+	if addr == "" {
+		addr = fmt.Sprintf(":%d", DefaultPort)
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.ServeListener(l)
+}
+
+// ServeListener serves requests from an existing listener
+func (s *Server) ServeListener(l net.Listener) error {
+	return gms.ServeListener(l, s, s.Logger)
+}
+
+// ServeConn serves a single request from c, and closes it
+func (s *Server) ServeConn(c net.Conn) {
+	defer c.Close()
+	self := &Item{Host: s.Host, Port: s.Port}
+	if a, ok := c.LocalAddr().(*net.TCPAddr); ok {
+		if self.Host == "" {
+			self.Host = a.IP.String()
+		}
+		if self.Port == 0 {
+			self.Port = a.Port
+		}
+	}
+
+	line, err := bufio.NewReader(io.LimitReader(c, 4096)).ReadString('\n')
+	if err != nil {
+		writeMenu(c, []*Item{{Type: ItemError, Display: "invalid request"}})
+		return
+	}
+	line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+	selector, search := line, ""
+	if i := strings.IndexByte(line, '\t'); i >= 0 {
+		selector, search = line[:i], line[i+1:]
+	}
+	if !strings.HasPrefix(selector, "/") {
+		selector = "/" + selector
+	}
+	u := &url.URL{Scheme: "gopher", Host: self.addr(), Path: selector}
+	if search != "" {
+		u.RawQuery = gemini.EscapeInput(search)
+	}
+
+	ctx := &gemini.Ctx{Req: &gemini.Request{URL: u}}
+	ctx.Res, _ = gemini.NewResponse(gemini.StatusSuccess, "")
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				s.log("panic while handling connection: %s", r)
+				ctx.Res, _ = gemini.NewResponse(gemini.StatusTemporaryFailure, "internal error")
+			}
+		}()
+		s.Handler.ServeGem(ctx)
+	}()
+	ctx.Res.Flush()
+
+	status, meta := ctx.Status(), ctx.Meta()
+	switch {
+	case status.IsInput():
+		writeMenu(c, []*Item{
+			{Type: ItemSearch, Display: meta, Selector: selector, Host: self.Host, Port: self.Port},
+		})
+	case status.IsSuccess():
+		mt, err := ctx.Res.MediaType()
+		if err != nil || mt.Mime() != gemtext.MediaType {
+			io.Copy(c, ctx.Res)
+			return
+		}
+		doc, err := gemtext.Parse(ctx.Res)
+		if err != nil {
+			writeMenu(c, []*Item{{Type: ItemError, Display: "invalid document"}})
+			return
+		}
+		writeMenu(c, DocumentMenu(doc, u, self))
+	case status.IsRedirect():
+		writeMenu(c, []*Item{
+			{Type: ItemInfo, Display: "This page has moved."},
+			linkItem(u, self, meta, meta),
+		})
+	default:
+		if meta == "" {
+			meta = status.Text()
+		}
+		writeMenu(c, []*Item{{Type: ItemError, Display: meta}})
+	}
+}
+
+// DocumentMenu turns a gemtext document into a gopher menu
+//
+// Links are resolved against base, those staying on base's host point to self, gopher links are kept as-is,
+// and everything else becomes a "URL:" html item.
+// Other lines become info items.
+func DocumentMenu(doc gemtext.Document, base *url.URL, self *Item) []*Item {
+	var out []*Item
+	info := func(s string) {
+		out = append(out, &Item{Type: ItemInfo, Display: strings.ReplaceAll(s, "\t", "    ")})
+	}
+	for _, v := range doc {
+		switch l := v.(type) {
+		case *gemtext.Link:
+			label := l.Label
+			if label == "" {
+				label = l.URL
+			}
+			out = append(out, linkItem(base, self, l.URL, label))
+		case *gemtext.Preformatted:
+			for _, line := range l.Lines {
+				info(line)
+			}
+		case *gemtext.PreformatToggle:
+			// nothing to show
+		default:
+			info(v.String())
+		}
+	}
+	return out
+}
+
+// linkItem turns a link into a menu item
+func linkItem(base *url.URL, self *Item, link, label string) *Item {
+	u, err := base.Parse(link)
+	if err != nil {
+		return &Item{Type: ItemInfo, Display: label}
+	}
+	if strings.HasPrefix(link, "gopher://") { // absolute gopher links use the url layout
+		if r, err := ParseURL(u.String()); err == nil {
+			return &Item{Type: r.Type, Display: label, Selector: r.Selector, Host: r.Host, Port: r.Port}
+		}
+	}
+	if u.Scheme == "gopher" || u.Scheme == "gemini" && u.Hostname() == base.Hostname() {
+		return &Item{Type: itemFor(u.Path), Display: label, Selector: u.Path, Host: self.Host, Port: self.Port}
+	}
+	return &Item{Type: ItemHTML, Display: label, Selector: "URL:" + u.String(), Host: self.Host, Port: self.Port}
+}
+
+func writeMenu(w io.Writer, menu []*Item) {
+	bw := bufio.NewWriter(w)
+	for _, v := range menu {
+		if v.Host == "" && (v.Type == ItemInfo || v.Type == ItemError) {
+			v.Host, v.Port = "error.host", 1 // traditional filler
+		}
+		fmt.Fprintf(bw, "%s\r\n", v)
+	}
+	bw.WriteString(".\r\n")
+	bw.Flush()
+}
+
+// itemFor guesses the item for a local path, as served by Server
+func itemFor(p string) ItemType {
+	ext := path.Ext(p)
+	switch {
+	case strings.HasSuffix(p, "/"), ext == "", ext == ".gmi", ext == ".gemini":
+		return ItemMenu
+	case ext == ".gif":
+		return ItemGIF
+	case ext == ".html", ext == ".htm":
+		return ItemHTML
+	}
+	mt := ItemBinary.MediaType(p)
+	switch {
+	case strings.HasPrefix(mt, "text/"):
+		return ItemText
+	case strings.HasPrefix(mt, "image/"):
+		return ItemImage
+	case strings.HasPrefix(mt, "audio/"):
+		return ItemSound
+	}
+	return ItemBinary
+}
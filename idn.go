@@ -0,0 +1,103 @@
+package gemini
+
+import (
+	"net"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// NormalizeHost returns the canonical ascii (punycode) form of a hostname, for use as a lookup key
+//
+// A Unicode hostname and its punycode spelling normalize to the same string.
+// The host may include a port, which is kept as-is.
+// Hosts that are not valid domain names (such as ip addresses) are only lowercased.
+func NormalizeHost(host string) string {
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		return net.JoinHostPort(NormalizeHost(h), port)
+	}
+	if ascii, err := ToASCIIHost(host); err == nil {
+		return ascii
+	}
+	return strings.ToLower(host)
+}
+
+// ToASCIIHost converts a hostname to its ascii (punycode) form, as it should be sent and dialed
+func ToASCIIHost(host string) (string, error) {
+	if net.ParseIP(host) != nil {
+		return host, nil
+	}
+	return idna.Lookup.ToASCII(strings.TrimSuffix(host, "."))
+}
+
+// ToUnicodeHost converts a hostname to its Unicode form, for display
+//
+// Hosts that cannot be converted are returned as-is.
+func ToUnicodeHost(host string) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	out, err := idna.Display.ToUnicode(host)
+	if err != nil {
+		return host
+	}
+	return out
+}
+
+// NormalizeHost converts the host of the request to its ascii (punycode) form in place
+//
+// The port, if any, is kept.
+// Ascii hosts that are not valid domain names, such as ones with underscores, are only lowercased.
+func (r *Request) NormalizeHost() error {
+	ascii, err := ToASCIIHost(r.URL.Hostname())
+	if err != nil {
+		host := r.URL.Hostname()
+		if !isASCII(host) {
+			return err
+		}
+		ascii = strings.ToLower(strings.TrimSuffix(host, "."))
+	}
+	if port := r.URL.Port(); port != "" {
+		ascii = net.JoinHostPort(ascii, port)
+	} else if strings.Contains(ascii, ":") { // ipv6
+		ascii = "[" + ascii + "]"
+	}
+	r.URL.Host = ascii
+	return nil
+}
+
+// UnicodeHost returns the hostname of the request in its Unicode form, for display
+func (r *Request) UnicodeHost() string {
+	return ToUnicodeHost(r.URL.Hostname())
+}
+
+// DisplayString returns the request as a string, with a Unicode hostname, for display
+//
+// Use String for anything other than display.
+func (r *Request) DisplayString() string {
+	if r.URL.Host == "" {
+		return r.String()
+	}
+	host := r.UnicodeHost()
+	if port := r.URL.Port(); port != "" {
+		host = net.JoinHostPort(host, port)
+	}
+
+	// url.URL would percent-encode the Unicode host
+	rest := *r.URL
+	rest.Scheme, rest.Host, rest.User = "", "", nil
+	prefix := "//"
+	if r.URL.Scheme != "" {
+		prefix = r.URL.Scheme + "://"
+	}
+	return prefix + host + rest.String()
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package gemini_test
+
+import (
+	"testing"
+
+	"toast.cafe/x/gemini"
+)
+
+func TestNormalizeHost(t *testing.T) {
+	for _, v := range []struct {
+		host   string
+		expect string
+	}{
+		{"bücher.example", "xn--bcher-kva.example"},
+		{"xn--bcher-kva.example", "xn--bcher-kva.example"},
+		{"BÜCHER.example", "xn--bcher-kva.example"},
+		{"Example.ORG", "example.org"},
+		{"bücher.example:1965", "xn--bcher-kva.example:1965"},
+		{"127.0.0.1", "127.0.0.1"},
+	} {
+		if out := gemini.NormalizeHost(v.host); out != v.expect {
+			t.Errorf("%q: expected %q, instead found %q", v.host, v.expect, out)
+		}
+	}
+}
+
+func TestRequestIDN(t *testing.T) {
+	r1, _ := gemini.ParseRequest("gemini://bücher.example/path")
+	r2, _ := gemini.ParseRequest("gemini://xn--bcher-kva.example/path")
+	if r1.Host() != r2.Host() {
+		t.Errorf("%q != %q", r1.Host(), r2.Host())
+	}
+	if r2.UnicodeHost() != "bücher.example" {
+		t.Errorf("expected %q, instead found %q", "bücher.example", r2.UnicodeHost())
+	}
+
+	if !r1.Canonicalize() {
+		t.Fatal("canonicalization failed")
+	}
+	if expect := "gemini://xn--bcher-kva.example:1965/path"; r1.String() != expect {
+		t.Errorf("expected %q, instead found %q", expect, r1.String())
+	}
+	if expect := "gemini://bücher.example:1965/path"; r1.DisplayString() != expect {
+		t.Errorf("expected %q, instead found %q", expect, r1.DisplayString())
+	}
+}
+
+func TestCanonicalizeUnderscore(t *testing.T) {
+	r, _ := gemini.ParseRequest("gemini://My_Host.example./path")
+	if !r.Canonicalize() {
+		t.Fatal("canonicalization failed")
+	}
+	if expect := "gemini://my_host.example:1965/path"; r.String() != expect {
+		t.Errorf("expected %q, instead found %q", expect, r.String())
+	}
+
+	r, _ = gemini.ParseRequest("gemini://bü_cher.example/path")
+	if r.Canonicalize() {
+		t.Errorf("expected an invalid Unicode host to be refused, instead found %q", r.String())
+	}
+}
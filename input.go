@@ -0,0 +1,69 @@
+package gemini
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// InputTooLongError is returned when user input does not fit in a request
+//
+// It unwraps to ErrRequestTooLong.
+type InputTooLongError struct {
+	Length    int // length of the encoded input
+	Available int // space left for the encoded input
+}
+
+func (e *InputTooLongError) Error() string {
+	return fmt.Sprintf("input too long: %d bytes once encoded, %d available", e.Length, e.Available)
+}
+
+// Unwrap returns ErrRequestTooLong
+func (e *InputTooLongError) Unwrap() error {
+	return ErrRequestTooLong
+}
+
+// HasInput returns true if the request carries user input, i.e. a query
+func (r *Request) HasInput() bool {
+	return r.URL.RawQuery != "" || r.URL.ForceQuery
+}
+
+// Input returns the user input carried by the request, percent-decoded
+func (r *Request) Input() (string, error) {
+	return url.PathUnescape(r.URL.RawQuery)
+}
+
+// InputSpace returns how many bytes of encoded input a follow-up request can carry, see WithInput
+//
+// The request is measured as it will be sent, canonicalized, since that can make it longer.
+func (r *Request) InputSpace() int {
+	u := *r.URL
+	u.RawQuery, u.ForceQuery, u.Fragment = "", true, ""
+	c := Request{&u}
+	c.Canonicalize() // if it fails, the request can't be sent anyway
+	n := MaxURL - len(u.String())
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// WithInput returns a copy of the request carrying the given user input, replacing any existing query
+//
+// If the encoded input does not fit within MaxURL, it returns an *InputTooLongError.
+func (r *Request) WithInput(input string) (*Request, error) {
+	enc := EscapeInput(input)
+	if avail := r.InputSpace(); len(enc) > avail {
+		return nil, &InputTooLongError{len(enc), avail}
+	}
+	u := *r.URL
+	u.RawQuery, u.ForceQuery, u.Fragment = enc, true, ""
+	return &Request{&u}, nil
+}
+
+// EscapeInput percent-encodes user input for use as a query
+//
+// Spaces are encoded as %20, as gemini does not use form encoding.
+func EscapeInput(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
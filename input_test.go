@@ -0,0 +1,52 @@
+package gemini_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"toast.cafe/x/gemini"
+)
+
+func TestRequestInput(t *testing.T) {
+	r, _ := gemini.ParseRequest("gemini://example.org/search")
+	if r.HasInput() {
+		t.Errorf("%q should not have input", r)
+	}
+
+	r2, err := r.WithInput("hello world & 100% +1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := "gemini://example.org/search?hello%20world%20%26%20100%25%20%2B1"; r2.String() != expect {
+		t.Errorf("expected %q, instead found %q", expect, r2.String())
+	}
+	in, err := r2.Input()
+	if err != nil || in != "hello world & 100% +1" {
+		t.Errorf("expected %q, instead found %q (%v)", "hello world & 100% +1", in, err)
+	}
+	if r.HasInput() {
+		t.Errorf("WithInput modified the original request")
+	}
+}
+
+func TestRequestInputTooLong(t *testing.T) {
+	r, _ := gemini.ParseRequest("//bücher.example/search")
+	avail := gemini.MaxURL - len("gemini://xn--bcher-kva.example:1965/search?")
+	if r.InputSpace() != avail {
+		t.Errorf("expected %d bytes of space, instead found %d", avail, r.InputSpace())
+	}
+
+	if _, err := r.WithInput(strings.Repeat("a", avail)); err != nil {
+		t.Errorf("expected input to fit, instead found %q", err)
+	}
+
+	_, err := r.WithInput(strings.Repeat(" ", avail))
+	var ite *gemini.InputTooLongError
+	if !errors.As(err, &ite) || ite.Length != 3*avail || ite.Available != avail {
+		t.Errorf("expected an InputTooLongError, instead found %v", err)
+	}
+	if !errors.Is(err, gemini.ErrRequestTooLong) {
+		t.Errorf("expected %q to match %q", err, gemini.ErrRequestTooLong)
+	}
+}
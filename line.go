@@ -0,0 +1,60 @@
+package gemini
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// errors internal to readLine, callers turn them into their own
+type lineError int
+
+const (
+	errLineTooLong lineError = iota
+	errLineTruncated
+)
+
+func (e lineError) Error() string {
+	if e == errLineTooLong {
+		return "line too long"
+	}
+	return "line truncated"
+}
+
+// bufReader reuses r if it is already buffered, so that nothing past what we read is lost to the caller
+func bufReader(r io.Reader, size int) *bufio.Reader {
+	if br, ok := r.(*bufio.Reader); ok {
+		return br
+	}
+	return bufio.NewReaderSize(r, size)
+}
+
+// readLine reads up to and including the first \r\n, returning the line without it
+//
+// It keeps reading through short reads, and does not consume anything past the \r\n.
+// If there is no \r\n within max bytes (including the \r\n), it returns errLineTooLong.
+// If the reader ends before the \r\n, it returns errLineTruncated, along with any other error from the reader.
+func readLine(br *bufio.Reader, max int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := br.ReadSlice('\n')
+		line = append(line, chunk...) // ReadSlice's result is only valid until the next read
+		if bytes.HasSuffix(line, []byte("\r\n")) {
+			if len(line) > max {
+				return nil, errLineTooLong
+			}
+			return line[:len(line)-2], nil
+		}
+		if len(line) >= max {
+			return nil, errLineTooLong
+		}
+		switch err {
+		case nil, bufio.ErrBufferFull: // a lone \n, or more to come
+			continue
+		case io.EOF:
+			return line, errLineTruncated
+		default:
+			return line, err
+		}
+	}
+}
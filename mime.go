@@ -0,0 +1,223 @@
+package gemini
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultMediaType is the media type implied by a success response with an empty meta
+const DefaultMediaType = "text/gemini; charset=utf-8"
+
+// MediaType is the parsed meta of a success response
+type MediaType struct {
+	Type    string // e.g. "text"
+	Subtype string // e.g. "gemini"
+
+	// Charset is the charset parameter, lowercased.
+	//
+	// As per the spec, it is "utf-8" when the parameter is missing.
+	Charset string
+
+	// Lang is the comma-separated list of the lang parameter, if any.
+	Lang []string
+
+	// Params holds all of the other parameters, keyed by their lowercased name.
+	Params map[string]string
+}
+
+// ParseMediaType parses the meta of a success response
+//
+// An empty meta results in DefaultMediaType.
+// Unlike mime.ParseMediaType, this accepts unquoted commas in parameter values, as used by the lang parameter in the spec.
+func ParseMediaType(meta string) (*MediaType, error) {
+	if strings.TrimSpace(meta) == "" {
+		meta = DefaultMediaType
+	}
+	parts, ok := splitParams(meta)
+	if !ok {
+		return nil, fmt.Errorf("%w: unterminated quoted string", ErrMediaType)
+	}
+	mt := strings.ToLower(strings.TrimSpace(parts[0]))
+	slash := strings.IndexByte(mt, '/')
+	if slash < 0 || !isToken(mt[:slash]) || !isToken(mt[slash+1:]) {
+		return nil, fmt.Errorf("%w: %q is not a type/subtype pair", ErrMediaType, mt)
+	}
+
+	out := MediaType{
+		Type:    mt[:slash],
+		Subtype: mt[slash+1:],
+		Charset: "utf-8",
+	}
+	for _, p := range parts[1:] {
+		p = strings.TrimSpace(p)
+		if p == "" { // trailing or doubled ;
+			continue
+		}
+		eq := strings.IndexByte(p, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("%w: parameter %q has no value", ErrMediaType, p)
+		}
+		k := strings.ToLower(strings.TrimSpace(p[:eq]))
+		v := strings.TrimSpace(p[eq+1:])
+		if !isToken(k) {
+			return nil, fmt.Errorf("%w: invalid parameter name %q", ErrMediaType, k)
+		}
+		if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+			v = unquote(v[1 : len(v)-1])
+		}
+
+		switch k {
+		case "charset":
+			out.Charset = strings.ToLower(v)
+		case "lang":
+			for _, l := range strings.Split(v, ",") {
+				if l = strings.TrimSpace(l); l != "" {
+					out.Lang = append(out.Lang, l)
+				}
+			}
+		default:
+			if out.Params == nil {
+				out.Params = make(map[string]string)
+			}
+			out.Params[k] = v
+		}
+	}
+	return &out, nil
+}
+
+// Mime returns the type/subtype pair, without parameters
+func (m *MediaType) Mime() string {
+	return m.Type + "/" + m.Subtype
+}
+
+// Is returns true if the media type has the given type/subtype pair, ignoring case
+func (m *MediaType) Is(mime string) bool {
+	return strings.EqualFold(m.Mime(), mime)
+}
+
+// Format returns the media type as a valid meta
+//
+// It returns an error if the parts cannot be represented, such as an invalid type or a parameter value with control characters.
+func (m *MediaType) Format() (string, error) {
+	if !isToken(m.Type) || !isToken(m.Subtype) {
+		return "", fmt.Errorf("%w: %q is not a type/subtype pair", ErrMediaType, m.Mime())
+	}
+	var b strings.Builder
+	b.WriteString(strings.ToLower(m.Mime()))
+
+	param := func(k, v string) error {
+		if !isToken(k) || strings.IndexFunc(v, isCtl) >= 0 {
+			return fmt.Errorf("%w: cannot format parameter %q", ErrMediaType, k)
+		}
+		b.WriteString("; ")
+		b.WriteString(strings.ToLower(k))
+		b.WriteByte('=')
+		if strings.IndexFunc(v, func(r rune) bool { return r != ',' && !isTokenRune(r) }) >= 0 || v == "" {
+			v = `"` + quoter.Replace(v) + `"`
+		}
+		b.WriteString(v)
+		return nil
+	}
+
+	if m.Charset != "" {
+		if err := param("charset", m.Charset); err != nil {
+			return "", err
+		}
+	}
+	if len(m.Lang) > 0 {
+		if err := param("lang", strings.Join(m.Lang, ",")); err != nil {
+			return "", err
+		}
+	}
+	keys := make([]string, 0, len(m.Params))
+	for k := range m.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys) // stable output
+	for _, k := range keys {
+		if err := param(k, m.Params[k]); err != nil {
+			return "", err
+		}
+	}
+
+	if b.Len() > MaxMeta {
+		return "", fmt.Errorf("%w: meta length > %d bytes", ErrMediaType, MaxMeta)
+	}
+	return b.String(), nil
+}
+
+// String returns the media type as a meta, or the empty string if it cannot be formatted
+func (m *MediaType) String() string {
+	out, _ := m.Format()
+	return out
+}
+
+// MediaType parses the meta of a success response
+//
+// A success response with an empty meta is treated as DefaultMediaType.
+// Other statuses don't carry a media type, and result in ErrMediaType.
+func (r *responseHeader) MediaType() (*MediaType, error) {
+	if r.Status/10 != StatusSuccess/10 {
+		return nil, fmt.Errorf("%w: status %d has no media type", ErrMediaType, r.Status)
+	}
+	return ParseMediaType(r.Meta())
+}
+
+// SetMediaType sets the meta of the response header to the formatted media type
+func (r *responseHeader) SetMediaType(m *MediaType) error {
+	s, err := m.Format()
+	if err != nil {
+		return err
+	}
+	r.SetMeta(s)
+	return nil
+}
+
+// ---- util
+
+// splitParams splits a meta on the semicolons outside of quoted strings
+//
+// It returns false if a quoted string is not terminated.
+func splitParams(s string) ([]string, bool) {
+	var parts []string
+	quoted, start := false, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quoted && s[i] == '\\':
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && s[i] == ';':
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:]), !quoted
+}
+
+var quoter = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func unquote(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// token as defined by RFC 2045
+func isToken(s string) bool {
+	return s != "" && strings.IndexFunc(s, func(r rune) bool { return !isTokenRune(r) }) < 0
+}
+
+func isTokenRune(r rune) bool {
+	return r > ' ' && r < 0x7f && !strings.ContainsRune(`()<>@,;:\"/[]?=`, r)
+}
+
+func isCtl(r rune) bool {
+	return r < ' ' && r != '\t' || r == 0x7f
+}
@@ -0,0 +1,65 @@
+package gemini_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"toast.cafe/x/gemini"
+)
+
+func TestParseMediaType(t *testing.T) {
+	for _, v := range []struct {
+		meta   string
+		expect *gemini.MediaType
+		err    error
+	}{
+		{"text/gemini; charset=UTF-8; lang=en,fr; format=flowed", &gemini.MediaType{"text", "gemini", "utf-8", []string{"en", "fr"}, map[string]string{"format": "flowed"}}, nil},
+		{"text/plain", &gemini.MediaType{"text", "plain", "utf-8", nil, nil}, nil},
+		{"", &gemini.MediaType{"text", "gemini", "utf-8", nil, nil}, nil},
+		{"text", nil, gemini.ErrMediaType},
+		{"text/plain; charset", nil, gemini.ErrMediaType},
+		{`text/plain; title="a; b=\"c\""; charset=ascii`, &gemini.MediaType{"text", "plain", "ascii", nil, map[string]string{"title": `a; b="c"`}}, nil},
+		{`text/plain; title="a; b`, nil, gemini.ErrMediaType},
+	} {
+		mt, err := gemini.ParseMediaType(v.meta)
+		if !errors.Is(err, v.err) {
+			t.Errorf("%q: expected error %q, instead found %q", v.meta, v.err, err)
+		}
+		if !reflect.DeepEqual(mt, v.expect) {
+			t.Errorf("%q: expected %+v, instead found %+v", v.meta, v.expect, mt)
+		}
+	}
+}
+
+func TestResponseMediaType(t *testing.T) {
+	var r gemini.Response
+	r.FromReader(strings.NewReader("20 \r\n"))
+	mt, err := r.MediaType()
+	if err != nil || mt.String() != gemini.DefaultMediaType {
+		t.Errorf("expected %q, instead found %q (%v)", gemini.DefaultMediaType, mt, err)
+	}
+
+	r.Reset()
+	r.FromReader(strings.NewReader("51 not found\r\n"))
+	if _, err := r.MediaType(); !errors.Is(err, gemini.ErrMediaType) {
+		t.Errorf("expected error %q, instead found %q", gemini.ErrMediaType, err)
+	}
+}
+
+func TestSetMediaType(t *testing.T) {
+	r, _ := gemini.NewResponse(gemini.StatusSuccess, "")
+	err := r.SetMediaType(&gemini.MediaType{Type: "text", Subtype: "gemini", Lang: []string{"en", "de"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := "text/gemini; lang=en,de"; r.Meta() != expect {
+		t.Errorf("expected meta %q, instead found %q", expect, r.Meta())
+	}
+
+	err = r.SetMediaType(&gemini.MediaType{Type: "te xt", Subtype: "gemini"})
+	if !errors.Is(err, gemini.ErrMediaType) {
+		t.Errorf("expected error %q, instead found %q", gemini.ErrMediaType, err)
+	}
+}
@@ -0,0 +1,81 @@
+package misfin
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/gmc"
+)
+
+// Client is a misfin client
+//
+// The identity of the sender goes in TLSConfig.Certificates, see Identity and SetIdentity.
+type Client struct {
+	TLSConfig *tls.Config
+
+	// Checker will be used by this client to verify hostnames, see gmc.Client.
+	//
+	// If nil, all certs are considered valid for all hosts.
+	Checker gmc.CertChecker
+}
+
+// NewClient creates a client sending as the given identity
+func NewClient(identity *tls.Certificate) *Client {
+	c := &Client{TLSConfig: &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: true,
+	}}
+	c.SetIdentity(identity)
+	return c
+}
+
+// SetIdentity sets the identity certificate of the sender
+func (c *Client) SetIdentity(identity *tls.Certificate) {
+	c.TLSConfig.Certificates = []tls.Certificate{*identity}
+}
+
+// Send sends message to the address, returning the response
+//
+// The response has no body, a successful response has the fingerprint of the recipient as meta.
+// Failure statuses are not errors, check the status of the response.
+func (c *Client) Send(to string, message string) (*gemini.Response, error) {
+	addr, err := ParseAddress(to)
+	if err != nil {
+		return nil, err
+	}
+	req := &Request{To: addr, Message: message}
+	if len(req.String())+2 > MaxRequest {
+		return nil, fmt.Errorf("%w: longer than %d bytes", ErrRequest, MaxRequest)
+	}
+
+	host, port := addr.Host, DefaultPort
+	if h, p, err := net.SplitHostPort(addr.Host); err == nil {
+		host, port = h, p
+	}
+	conf := c.TLSConfig.Clone()
+	if conf.ServerName == "" {
+		conf.ServerName = host
+	}
+	con, err := tls.Dial("tcp", net.JoinHostPort(host, port), conf)
+	if err != nil {
+		return nil, err
+	}
+	defer con.Close()
+
+	if c.Checker != nil {
+		if err := c.Checker.VerifyCert(host, con.ConnectionState().PeerCertificates); err != nil {
+			return nil, fmt.Errorf("VerifyCert returned an error: %w", err)
+		}
+	}
+
+	if _, err := fmt.Fprintf(con, "%s\r\n", req); err != nil {
+		return nil, err
+	}
+	res := new(gemini.Response)
+	if err := res.FromReader(con); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,188 @@
+// Package misfin implements misfin, gemini-native mail.
+//
+// A misfin request is a single "misfin://mailbox@host message\r\n" line sent over TLS.
+// Senders authenticate with a client certificate whose common name is their display name,
+// whose user id is their mailbox, and whose first dns name is their host, see IdentityTemplate.
+// Responses are gemini response headers.
+package misfin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"strings"
+	"time"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/cert"
+)
+
+type misfinError string
+
+func (e misfinError) Error() string { return string(e) }
+
+// Misfin errors
+const (
+	ErrAddress   = misfinError("invalid misfin address")
+	ErrRequest   = misfinError("invalid misfin request")
+	ErrIdentity  = misfinError("invalid misfin identity")
+	ErrNoMailbox = misfinError("mailbox does not exist")
+)
+
+// DefaultPort is the default misfin port
+const DefaultPort = "1958"
+
+// MaxRequest is the maximum length of a request, including the CRLF
+const MaxRequest = 2048
+
+// StatusSenderMismatch is the misfin status for senders whose certificate does not match what is known about them
+const StatusSenderMismatch gemini.Status = 63
+
+// oidUserID is the LDAP user id attribute, which holds the mailbox in identities
+var oidUserID = asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 1}
+
+// Address is a misfin address, mailbox@host
+type Address struct {
+	Mailbox string
+	Host    string
+}
+
+// ParseAddress parses a mailbox@host address, with or without the misfin:// scheme
+func ParseAddress(s string) (Address, error) {
+	s = strings.TrimPrefix(s, "misfin://")
+	i := strings.LastIndexByte(s, '@')
+	if i <= 0 || i == len(s)-1 || strings.ContainsAny(s, " \t\r\n/") {
+		return Address{}, fmt.Errorf("%w: %q", ErrAddress, s)
+	}
+	return Address{Mailbox: s[:i], Host: gemini.NormalizeHost(s[i+1:])}, nil
+}
+
+// String returns the address as mailbox@host
+func (a Address) String() string {
+	return a.Mailbox + "@" + a.Host
+}
+
+// Request is a misfin request
+type Request struct {
+	To      Address
+	Message string
+}
+
+// ParseRequest parses a request line, without its terminator
+func ParseRequest(line string) (*Request, error) {
+	if !strings.HasPrefix(line, "misfin://") {
+		return nil, fmt.Errorf("%w: missing misfin:// scheme", ErrRequest)
+	}
+	addr, msg := line, ""
+	if i := strings.IndexByte(line, ' '); i >= 0 {
+		addr, msg = line[:i], line[i+1:]
+	}
+	to, err := ParseAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Request{To: to, Message: msg}, nil
+}
+
+// String returns the request line, without its terminator
+func (r *Request) String() string {
+	return "misfin://" + r.To.String() + " " + r.Message
+}
+
+// Message is a received message
+type Message struct {
+	From        Address
+	Name        string // display name of the sender, may be empty
+	Fingerprint string // of the sender's certificate, see cert.Fingerprint
+	To          Address
+	Received    time.Time
+	Body        string // gemtext
+}
+
+// String returns the message as gemmail: the sender and timestamp lines, followed by the body
+func (m *Message) String() string {
+	var b strings.Builder
+	b.WriteString("< " + m.From.String())
+	if m.Name != "" {
+		b.WriteString(" " + m.Name)
+	}
+	b.WriteString("\n@ " + m.Received.UTC().Format(time.RFC3339) + "\n")
+	b.WriteString(m.Body)
+	return b.String()
+}
+
+// ParseMessage parses gemmail as written by Message.String
+//
+// Only the leading sender and timestamp lines are interpreted, To and Fingerprint are left empty.
+func ParseMessage(s string) (*Message, error) {
+	m := new(Message)
+	for {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			break
+		}
+		line := s[:i]
+		switch {
+		case strings.HasPrefix(line, "< "):
+			fields := strings.SplitN(line[2:], " ", 2)
+			from, err := ParseAddress(fields[0])
+			if err != nil {
+				return nil, err
+			}
+			m.From = from
+			if len(fields) > 1 {
+				m.Name = fields[1]
+			}
+		case strings.HasPrefix(line, "@ "):
+			t, err := time.Parse(time.RFC3339, line[2:])
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid timestamp %q", ErrRequest, line[2:])
+			}
+			m.Received = t
+		default:
+			m.Body = s
+			return m, nil
+		}
+		s = s[i+1:]
+	}
+	m.Body = s
+	return m, nil
+}
+
+// IdentityTemplate returns a template for the identity certificate of a sender, see cert.Pool.GetTemplate
+func IdentityTemplate(from Address, name string) *x509.Certificate {
+	return &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName: name,
+			ExtraNames: []pkix.AttributeTypeAndValue{{Type: oidUserID, Value: from.Mailbox}},
+		},
+		DNSNames:              []string{from.Host},
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+}
+
+// Identity gets the identity certificate of a sender from the pool, generating it if needed
+func Identity(pool *cert.Pool, from Address, name string) (*tls.Certificate, error) {
+	return pool.GetTemplate(from.String(), IdentityTemplate(from, name))
+}
+
+// Sender extracts the address and display name of a sender from their identity certificate
+func Sender(c *x509.Certificate) (Address, string, error) {
+	var from Address
+	for _, v := range c.Subject.Names {
+		if v.Type.Equal(oidUserID) {
+			from.Mailbox, _ = v.Value.(string)
+		}
+	}
+	if len(c.DNSNames) > 0 {
+		from.Host = gemini.NormalizeHost(c.DNSNames[0])
+	}
+	if from.Mailbox == "" || from.Host == "" {
+		return Address{}, "", fmt.Errorf("%w: missing user id or dns name", ErrIdentity)
+	}
+	return from, c.Subject.CommonName, nil
+}
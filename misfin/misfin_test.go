@@ -0,0 +1,181 @@
+package misfin_test
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/cert"
+	"toast.cafe/x/gemini/misfin"
+)
+
+func TestParseRequest(t *testing.T) {
+	req, err := misfin.ParseRequest("misfin://alice@Example.ORG hello\nworld")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.To != (misfin.Address{Mailbox: "alice", Host: "example.org"}) || req.Message != "hello\nworld" {
+		t.Errorf("got %+v", req)
+	}
+	if s := req.String(); s != "misfin://alice@example.org hello\nworld" {
+		t.Errorf("got %q", s)
+	}
+	for _, v := range []string{"gemini://alice@example.org hi", "misfin://example.org hi", "misfin://alice@ hi"} {
+		if _, err := misfin.ParseRequest(v); err == nil {
+			t.Errorf("%q: expected an error", v)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	m := &misfin.Message{
+		From:     misfin.Address{Mailbox: "bob", Host: "example.net"},
+		Name:     "Bob B.",
+		Received: time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC),
+		Body:     "# Hi\n< not a sender\n",
+	}
+	s := m.String()
+	if want := "< bob@example.net Bob B.\n@ 2023-06-01T12:00:00Z\n# Hi\n< not a sender\n"; s != want {
+		t.Fatalf("got %q, want %q", s, want)
+	}
+	got, err := misfin.ParseMessage(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.From != m.From || got.Name != m.Name || !got.Received.Equal(m.Received) || got.Body != m.Body {
+		t.Errorf("got %+v, want %+v", got, m)
+	}
+}
+
+func testStorage(t *testing.T, s misfin.Storage) {
+	m := &misfin.Message{From: misfin.Address{Mailbox: "bob", Host: "example.net"}, Received: time.Now(), Body: "one"}
+	if err := s.Deliver("nobody", m); err != misfin.ErrNoMailbox {
+		t.Errorf("expected ErrNoMailbox, got %v", err)
+	}
+	for i, body := range []string{"one", "two"} {
+		m := *m
+		m.Body = body
+		m.Received = m.Received.Add(time.Duration(i) * time.Second)
+		if err := s.Deliver("alice", &m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ms, err := s.Messages("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 2 || ms[0].Body != "one" || ms[1].Body != "two" || ms[0].From != m.From {
+		t.Errorf("got %+v", ms)
+	}
+}
+
+func TestMemoryStorage(t *testing.T) {
+	testStorage(t, misfin.NewMemoryStorage("alice"))
+}
+
+func TestDirStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "misfin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := misfin.NewDirStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Create("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Create("../escape"); err == nil {
+		t.Error("expected an error for a bad mailbox name")
+	}
+	testStorage(t, s)
+	if fs, _ := ioutil.ReadDir(filepath.Join(dir, "alice", "new")); len(fs) != 2 {
+		t.Errorf("expected 2 files in new, got %d", len(fs))
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "misfin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pool, err := cert.NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen:", err)
+	}
+	defer l.Close()
+	serverCert, err := pool.Get("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	known, err := cert.NewKnownHosts(filepath.Join(dir, "senders.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	boxes := misfin.NewMemoryStorage("alice")
+	srv := &misfin.Server{
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{*serverCert}},
+		Mailboxes: boxes,
+		Checker:   known,
+	}
+	go srv.ServeListener(l)
+	to := "alice@" + l.Addr().String()
+
+	bob := misfin.Address{Mailbox: "bob", Host: "example.net"}
+	id, err := misfin.Identity(pool, bob, "Bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := misfin.NewClient(id)
+
+	res, err := c.Send(to, "hello\nalice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != gemini.StatusSuccess || res.Meta() == "" {
+		t.Errorf("got %d %s", res.Status, res.Meta())
+	}
+	ms, _ := boxes.Messages("alice")
+	if len(ms) != 1 || ms[0].From != bob || ms[0].Name != "Bob" || ms[0].Body != "hello\nalice" || ms[0].Fingerprint == "" {
+		t.Fatalf("got %+v", ms)
+	}
+
+	if res, err := c.Send("nobody@"+l.Addr().String(), "hi"); err != nil || res.Status != gemini.StatusNotFound {
+		t.Errorf("unknown mailbox: got %v %v", res, err)
+	}
+
+	// another certificate claiming to be bob
+	other, err := cert.NewStore(filepath.Join(dir, "other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake, err := misfin.Identity(other, bob, "Not Bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err := misfin.NewClient(fake).Send(to, "hi"); err != nil || res.Status != misfin.StatusSenderMismatch {
+		t.Errorf("impostor: got %v %v", res, err)
+	}
+
+	// a certificate that isn't an identity
+	if res, err := misfin.NewClient(serverCert).Send(to, "hi"); err != nil || res.Status != gemini.StatusCertificateNotValid {
+		t.Errorf("no identity: got %v %v", res, err)
+	}
+
+	if _, err := c.Send(to, strings.Repeat("x", misfin.MaxRequest)); err == nil {
+		t.Error("expected an error for an overlong message")
+	}
+}
@@ -0,0 +1,177 @@
+package misfin
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/cert"
+	"toast.cafe/x/gemini/gmc"
+	"toast.cafe/x/gemini/gms"
+)
+
+// Server is a misfin server, delivering mail to Mailboxes
+//
+// Senders must present an identity certificate (see Sender), which is not verified against any CA.
+// On success, the server answers with the fingerprint of its own certificate for the recipient host.
+type Server struct {
+	// TCP address to listen on, defaults to :1958
+	Addr string
+	// TLSConfig must provide the server certificates, client certificates are requested regardless of its settings.
+	TLSConfig *tls.Config
+	Mailboxes Storage
+	Logger    gms.Logger
+
+	// Hosts restricts the hosts mail is accepted for, no restriction if empty.
+	Hosts []string
+	// Checker verifies senders, it gets the sender address and their certificate chain.
+	//
+	// cert.KnownHosts makes a good trust-on-first-use checker, keyed on fingerprints.
+	// If nil, any identity is accepted.
+	Checker gmc.CertChecker
+}
+
+func (s *Server) log(fmt string, args ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Printf(fmt, args...)
+	}
+}
+
+func (s *Server) tlsConfig() *tls.Config {
+	conf := s.TLSConfig.Clone()
+	conf.ClientAuth = tls.RequireAnyClientCert
+	return conf
+}
+
+// Serve listens on Addr and serves requests
+func (s *Server) Serve() error {
+	addr := s.Addr
+	if addr == "" {
+		addr = ":" + DefaultPort
+	}
+	l, err := tls.Listen("tcp", addr, s.tlsConfig())
+	if err != nil {
+		return err
+	}
+	return gms.ServeListener(l, gms.ConnHandlerFunc(s.serveConn), s.Logger)
+}
+
+// ServeListener serves requests from an existing (plain TCP) listener
+func (s *Server) ServeListener(l net.Listener) error {
+	return gms.ServeListener(tls.NewListener(l, s.tlsConfig()), gms.ConnHandlerFunc(s.serveConn), s.Logger)
+}
+
+func (s *Server) serveConn(c net.Conn) {
+	defer c.Close()
+	status, meta := s.handle(c)
+	fmt.Fprintf(c, "%d %s\r\n", status, meta)
+}
+
+// handle reads and delivers the message, returning the response header
+func (s *Server) handle(c net.Conn) (gemini.Status, string) {
+	tc, ok := c.(*tls.Conn)
+	if !ok {
+		return gemini.StatusPermanentFailure, "tls required"
+	}
+	if err := tc.Handshake(); err != nil {
+		s.log("error during handshake: %s", err)
+		return gemini.StatusClientCertificateRequires, "identity required"
+	}
+	state := tc.ConnectionState()
+	certs := state.PeerCertificates
+	if len(certs) == 0 {
+		return gemini.StatusClientCertificateRequires, "identity required"
+	}
+
+	line, err := readRequest(bufio.NewReader(c))
+	if err != nil {
+		return gemini.StatusBadRequest, err.Error()
+	}
+	req, err := ParseRequest(line)
+	if err != nil {
+		return gemini.StatusBadRequest, err.Error()
+	}
+	if !s.accepts(req.To.Host) {
+		return gemini.StatusProxyRequestRefused, "domain not serviced"
+	}
+
+	from, name, err := Sender(certs[0])
+	if err != nil {
+		return gemini.StatusCertificateNotValid, err.Error()
+	}
+	if s.Checker != nil {
+		if err := s.Checker.VerifyCert(from.String(), certs); err != nil {
+			return StatusSenderMismatch, "certificate does not match sender"
+		}
+	}
+
+	m := &Message{
+		From:        from,
+		Name:        name,
+		Fingerprint: cert.Fingerprint(certs[0]),
+		To:          req.To,
+		Received:    time.Now(),
+		Body:        req.Message,
+	}
+	if err := s.Mailboxes.Deliver(req.To.Mailbox, m); err != nil {
+		if errors.Is(err, ErrNoMailbox) {
+			return gemini.StatusNotFound, "mailbox does not exist"
+		}
+		s.log("error while delivering to %s: %s", req.To, err)
+		return gemini.StatusTemporaryFailure, "could not deliver"
+	}
+	return gemini.StatusSuccess, s.fingerprint(state.ServerName)
+}
+
+func (s *Server) accepts(host string) bool {
+	if len(s.Hosts) == 0 {
+		return true
+	}
+	for _, v := range s.Hosts {
+		if gemini.NormalizeHost(v) == host {
+			return true
+		}
+	}
+	return false
+}
+
+// fingerprint returns the fingerprint of the certificate served for the host, if it can be found
+func (s *Server) fingerprint(host string) string {
+	var c *tls.Certificate
+	switch {
+	case s.TLSConfig.GetCertificate != nil:
+		c, _ = s.TLSConfig.GetCertificate(&tls.ClientHelloInfo{ServerName: host})
+	case len(s.TLSConfig.Certificates) > 0:
+		c = &s.TLSConfig.Certificates[0]
+	}
+	if c == nil || len(c.Certificate) == 0 {
+		return ""
+	}
+	leaf, err := x509.ParseCertificate(c.Certificate[0])
+	if err != nil {
+		return ""
+	}
+	return cert.Fingerprint(leaf)
+}
+
+// readRequest reads a request line terminated by CRLF, messages can contain lone LFs
+func readRequest(br *bufio.Reader) (string, error) {
+	var b strings.Builder
+	for b.Len() < MaxRequest {
+		c, err := br.ReadByte()
+		if err != nil {
+			return "", fmt.Errorf("%w: truncated", ErrRequest)
+		}
+		b.WriteByte(c)
+		if c == '\n' && strings.HasSuffix(b.String(), "\r\n") {
+			return strings.TrimSuffix(b.String(), "\r\n"), nil
+		}
+	}
+	return "", fmt.Errorf("%w: longer than %d bytes", ErrRequest, MaxRequest)
+}
@@ -0,0 +1,172 @@
+package misfin
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Storage holds mailboxes
+//
+// Implementations must be safe for concurrent use.
+type Storage interface {
+	// Deliver stores m in the mailbox, returning ErrNoMailbox if it does not exist.
+	Deliver(mailbox string, m *Message) error
+	// Messages returns the messages in the mailbox, oldest first.
+	Messages(mailbox string) ([]*Message, error)
+}
+
+// MemoryStorage is a Storage that keeps messages in memory
+type MemoryStorage struct {
+	mu    sync.Mutex
+	boxes map[string][]*Message
+}
+
+// NewMemoryStorage creates a MemoryStorage with the given mailboxes
+func NewMemoryStorage(mailboxes ...string) *MemoryStorage {
+	s := &MemoryStorage{boxes: make(map[string][]*Message)}
+	for _, v := range mailboxes {
+		s.Create(v)
+	}
+	return s
+}
+
+// Create creates an empty mailbox, if it does not exist already
+func (s *MemoryStorage) Create(mailbox string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.boxes[mailbox]; !ok {
+		s.boxes[mailbox] = nil
+	}
+}
+
+// Deliver implements Storage
+func (s *MemoryStorage) Deliver(mailbox string, m *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	box, ok := s.boxes[mailbox]
+	if !ok {
+		return ErrNoMailbox
+	}
+	s.boxes[mailbox] = append(box, m)
+	return nil
+}
+
+// Messages implements Storage
+func (s *MemoryStorage) Messages(mailbox string) ([]*Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	box, ok := s.boxes[mailbox]
+	if !ok {
+		return nil, ErrNoMailbox
+	}
+	return append([]*Message(nil), box...), nil
+}
+
+// DirStorage is a Storage backed by a maildir-like directory
+//
+// Each mailbox is a subdirectory, with tmp, new and cur subdirectories of its own.
+// Messages are written to tmp and renamed into new, so that readers never see partial messages.
+// Messages in new and cur are both listed, moving them between the two is up to the mail reader.
+// Messages are stored as gemmail (see Message.String), so their Fingerprint is not kept.
+type DirStorage struct {
+	dir string
+}
+
+// NewDirStorage opens the given directory, creating it if needed
+func NewDirStorage(dir string) (*DirStorage, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &DirStorage{dir}, nil
+}
+
+func (s *DirStorage) box(mailbox string) (string, error) {
+	if mailbox == "" || strings.ContainsAny(mailbox, `/\`) || strings.HasPrefix(mailbox, ".") {
+		return "", ErrNoMailbox
+	}
+	return filepath.Join(s.dir, mailbox), nil
+}
+
+// Create creates an empty mailbox, if it does not exist already
+func (s *DirStorage) Create(mailbox string) error {
+	box, err := s.box(mailbox)
+	if err != nil {
+		return fmt.Errorf("%w: invalid mailbox name %q", ErrAddress, mailbox)
+	}
+	for _, v := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(box, v), 0700); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deliver implements Storage
+func (s *DirStorage) Deliver(mailbox string, m *Message) error {
+	box, err := s.box(mailbox)
+	if err != nil {
+		return err
+	}
+	if fi, err := os.Stat(filepath.Join(box, "new")); err != nil || !fi.IsDir() {
+		return ErrNoMailbox
+	}
+
+	var rnd [8]byte
+	if _, err := rand.Read(rnd[:]); err != nil {
+		return err
+	}
+	// sorts by delivery time
+	name := fmt.Sprintf("%020d.%s.gmi", m.Received.UnixNano(), hex.EncodeToString(rnd[:]))
+	tmp := filepath.Join(box, "tmp", name)
+	if err := ioutil.WriteFile(tmp, []byte(m.String()), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(box, "new", name))
+}
+
+// Messages implements Storage
+func (s *DirStorage) Messages(mailbox string) ([]*Message, error) {
+	box, err := s.box(mailbox)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(box); err != nil {
+		return nil, ErrNoMailbox
+	}
+
+	var paths []string
+	for _, sub := range []string{"new", "cur"} {
+		infos, err := ioutil.ReadDir(filepath.Join(box, sub))
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range infos {
+			if !v.IsDir() && !strings.HasPrefix(v.Name(), ".") {
+				paths = append(paths, filepath.Join(box, sub, v.Name()))
+			}
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool { return filepath.Base(paths[i]) < filepath.Base(paths[j]) })
+
+	out := make([]*Message, 0, len(paths))
+	for _, v := range paths {
+		b, err := ioutil.ReadFile(v)
+		if err != nil {
+			return nil, err
+		}
+		m, err := ParseMessage(string(b))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(v), err)
+		}
+		m.To = Address{Mailbox: mailbox}
+		out = append(out, m)
+	}
+	return out, nil
+}
@@ -0,0 +1,146 @@
+package nex
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/gemtext"
+	"toast.cafe/x/gemini/gms"
+)
+
+// DefaultMaxSize is the largest response a Client reads by default
+const DefaultMaxSize = 16 << 20
+
+// Client is a nex client
+type Client struct {
+	Dialer net.Dialer
+
+	// MaxSize is the largest response read, DefaultMaxSize if zero.
+	MaxSize int64
+}
+
+// DefaultClient is the default
+var DefaultClient = &Client{}
+
+// Response is a nex response
+type Response struct {
+	URL  *url.URL
+	Body []byte
+}
+
+// Fetch requests a nex URL, nex://host[:port]/path
+func (c *Client) Fetch(s string) (*Response, error) {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != "nex" || u.Host == "" {
+		return nil, fmt.Errorf("%w: expected an absolute nex url", ErrURL)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), DefaultPort)
+	}
+	con, err := c.Dialer.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer con.Close()
+
+	if _, err := fmt.Fprintf(con, "%s\r\n", strings.TrimPrefix(u.Path, "/")); err != nil {
+		return nil, err
+	}
+	max := c.MaxSize
+	if max <= 0 {
+		max = DefaultMaxSize
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(con, max))
+	if err != nil {
+		return nil, err
+	}
+	return &Response{URL: u, Body: body}, nil
+}
+
+// IsDirectory returns true if the response is a directory listing
+func (r *Response) IsDirectory() bool {
+	return IsDirectory(r.URL.Path)
+}
+
+// Document returns the response as gemtext
+//
+// Directory listings have their link lines resolved against the request, files become a single preformatted block.
+func (r *Response) Document() gemtext.Document {
+	text := strings.TrimSuffix(strings.ReplaceAll(string(r.Body), "\r\n", "\n"), "\n")
+	if !r.IsDirectory() {
+		return gemtext.Document{&gemtext.Preformatted{Alt: path.Base(r.URL.Path), Lines: strings.Split(text, "\n")}}
+	}
+
+	var out gemtext.Document
+	sc := bufio.NewScanner(strings.NewReader(text))
+	for sc.Scan() {
+		line := sc.Text()
+		l, ok := gemtext.ParseLine(line).(*gemtext.Link)
+		if !ok { // including link lines without a url
+			out = append(out, gemtext.Text(line))
+			continue
+		}
+		if u, err := r.URL.Parse(l.URL); err == nil {
+			l.URL = u.String()
+		}
+		out = append(out, l)
+	}
+	return out
+}
+
+// ProxyHandler returns a Handler that serves nex:// proxy requests
+//
+// Directory listings are served as gemtext, files as-is, with a media type guessed from their extension.
+// Requests for other schemes are refused.
+// If client is nil, DefaultClient is used.
+//
+// Loopback and private addresses are refused too, see gms.PublicAddressesOnly, unless the client's dialer has its own Control function.
+// Only set one if the handler is not reachable by untrusted users, or as an allow list: an open proxy exposes the server's network.
+func ProxyHandler(client *Client) gms.HandlerFunc {
+	if client == nil {
+		client = DefaultClient
+	}
+	c := *client
+	c.Dialer = gms.ProxyDialer(c.Dialer)
+	client = &c
+	return func(ctx *gemini.Ctx) {
+		if ctx.Req.URL.Scheme != "nex" {
+			ctx.Res.Status = gemini.StatusProxyRequestRefused
+			ctx.Res.SetMeta("only nex is proxied")
+			return
+		}
+		res, err := client.Fetch(ctx.Req.URL.String())
+		if errors.Is(err, gms.ErrForbiddenAddress) {
+			ctx.Res.Status = gemini.StatusProxyRequestRefused
+			ctx.Res.SetMeta("refusing to proxy to a non-public address")
+			return
+		} else if err != nil {
+			ctx.Res.Status = gemini.StatusProxyError
+			ctx.Res.SetMeta("upstream request failed")
+			return
+		}
+
+		ctx.Res.Status = gemini.StatusSuccess
+		if res.IsDirectory() {
+			ctx.Res.SetMeta(gemtext.MediaType + "; charset=utf-8")
+			gemtext.NewWriter(ctx.Res).Document(res.Document())
+			return
+		}
+		mt := mime.TypeByExtension(path.Ext(res.URL.Path))
+		if mt == "" {
+			mt = "text/plain; charset=utf-8" // most of nexspace is text
+		}
+		ctx.Res.SetMeta(mt)
+		ctx.Res.Write(res.Body)
+	}
+}
@@ -0,0 +1,128 @@
+// Package nex implements the nex protocol, with a server, a client, and a gemini proxy handler.
+//
+// Nex is plain TCP: the client sends a path, and the server answers with a directory listing or a file and hangs up.
+// Directory listings are text, with "=> url" link lines like gemtext.
+package nex
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"toast.cafe/x/gemini/gms"
+)
+
+type nexError string
+
+func (e nexError) Error() string { return string(e) }
+
+// Nex errors
+const (
+	ErrURL = nexError("invalid nex url")
+)
+
+// DefaultPort is the default nex port
+const DefaultPort = "1900"
+
+// IsDirectory returns true if the path refers to a directory listing, which is the case for empty paths and paths ending in /
+func IsDirectory(p string) bool {
+	return p == "" || strings.HasSuffix(p, "/")
+}
+
+// A Handler responds to a nex request.
+//
+// ServeNex gets the requested path, and should write the listing or file to w.
+type Handler interface {
+	ServeNex(w io.Writer, path string)
+}
+
+// HandlerFunc is an adapter that allows using standalone functions as nex Handlers
+type HandlerFunc func(io.Writer, string)
+
+// ServeNex calls f(w, path)
+func (f HandlerFunc) ServeNex(w io.Writer, path string) {
+	f(w, path)
+}
+
+// FileHandler returns a Handler that serves the files in dir
+//
+// Directories are listed, subdirectories having a trailing /, and dotfiles are hidden.
+// Missing files get a short error message, as nex has no way to report errors.
+func FileHandler(dir string) HandlerFunc {
+	return func(w io.Writer, p string) {
+		clean := path.Clean("/" + p) // rooted, so .. can't escape
+		full := filepath.Join(dir, filepath.FromSlash(clean))
+		if strings.Contains(clean, "/.") { // hidden, same as in listings
+			io.WriteString(w, "not found\n")
+			return
+		}
+
+		if !IsDirectory(p) && clean != "/" {
+			f, err := os.Open(full)
+			if err == nil {
+				defer f.Close()
+				if fi, err := f.Stat(); err == nil && !fi.IsDir() {
+					io.Copy(w, f)
+					return
+				}
+			}
+			io.WriteString(w, "not found\n")
+			return
+		}
+
+		infos, err := ioutil.ReadDir(full)
+		if err != nil {
+			io.WriteString(w, "not found\n")
+			return
+		}
+		sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+		for _, v := range infos {
+			name := v.Name()
+			if strings.HasPrefix(name, ".") {
+				continue
+			}
+			if v.IsDir() {
+				name += "/"
+			}
+			fmt.Fprintf(w, "=> %s\n", (&url.URL{Path: name}).String())
+		}
+	}
+}
+
+// Server is a nex server
+type Server struct {
+	// TCP address to listen on, defaults to :1900
+	Addr    string
+	Handler Handler
+	Logger  gms.Logger
+}
+
+func (s *Server) line() *gms.LineServer {
+	addr := s.Addr
+	if addr == "" {
+		addr = ":" + DefaultPort
+	}
+	return &gms.LineServer{Addr: addr, Handler: gms.LineHandlerFunc(s.ServeLine), Logger: s.Logger}
+}
+
+// Serve listens on Addr and serves requests
+func (s *Server) Serve() error {
+	return s.line().Serve()
+}
+
+// ServeListener serves requests from an existing listener
+func (s *Server) ServeListener(l net.Listener) error {
+	return s.line().ServeListener(l)
+}
+
+// ServeLine answers a single request line, see gms.LineHandler
+func (s *Server) ServeLine(w io.Writer, line string) {
+	s.Handler.ServeNex(w, line)
+}
@@ -0,0 +1,82 @@
+package nex_test
+
+import (
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/nex"
+)
+
+func TestRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "sub dir"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "sub dir", "a.txt"), []byte("a\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, ".hidden"), []byte("secret\n"), 0644)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen:", err)
+	}
+	defer l.Close()
+	go (&nex.Server{Handler: nex.FileHandler(dir)}).ServeListener(l)
+	base := "nex://" + l.Addr().String() + "/"
+
+	for _, tc := range []struct {
+		path, body, doc string
+	}{
+		{"", "=> hello.txt\n=> sub%20dir/\n", "=> " + base + "hello.txt\n=> " + base + "sub%20dir/\n"},
+		{"sub%20dir/", "=> a.txt\n", "=> " + base + "sub%20dir/a.txt\n"},
+		{"hello.txt", "hello\n", "```hello.txt\nhello\n```\n"},
+		{"../../etc/passwd", "not found\n", "```passwd\nnot found\n```\n"},
+		{".hidden/", "not found\n", "not found\n"},
+		{".hidden", "not found\n", "```.hidden\nnot found\n```\n"},
+	} {
+		res, err := nex.DefaultClient.Fetch(base + tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(res.Body) != tc.body {
+			t.Errorf("%s: got body %q, want %q", tc.path, res.Body, tc.body)
+		}
+		if doc := res.Document().String(); doc != tc.doc {
+			t.Errorf("%s: got document %q, want %q", tc.path, doc, tc.doc)
+		}
+	}
+
+	ctx, _ := gemini.NewRequestCtx(base)
+	ctx.Res = new(gemini.Response)
+	nex.ProxyHandler(nil)(ctx)
+	if ctx.Status() != gemini.StatusProxyRequestRefused {
+		t.Errorf("proxy: got status %d for a loopback address, want %d", ctx.Status(), gemini.StatusProxyRequestRefused)
+	}
+
+	ctx, _ = gemini.NewRequestCtx(base)
+	ctx.Res = new(gemini.Response)
+	ctx.Res.ServerPrepare()
+	nex.ProxyHandler(&nex.Client{Dialer: net.Dialer{Control: allowAll}})(ctx)
+	ctx.Res.Flush()
+	if ctx.Status() != gemini.StatusSuccess || ctx.Meta() != "text/gemini; charset=utf-8" {
+		t.Errorf("proxy: got %q", ctx.Header())
+	}
+}
+
+func TestDocumentBadLinks(t *testing.T) {
+	u, _ := url.Parse("nex://example.org/dir/")
+	res := &nex.Response{URL: u, Body: []byte("=>\n=>   \nhi\n=> a b\n")}
+	if doc, want := res.Document().String(), "=>\n=>   \nhi\n=> nex://example.org/dir/a b\n"; doc != want {
+		t.Errorf("got document %q, want %q", doc, want)
+	}
+}
+
+func allowAll(network, address string, c syscall.RawConn) error { return nil }
@@ -0,0 +1,145 @@
+package gemini
+
+import "strings"
+
+// DefaultPort is the default gemini port
+const DefaultPort = "1965"
+
+// Key returns a canonical string form of the request, suitable for deduplication, caching and comparison
+//
+// It applies RFC 3986 syntax-based normalization:
+// the scheme and host are lowercased (and the host converted to punycode, see NormalizeHost),
+// the default port is dropped, percent-encodings are uppercased and unreserved characters decoded,
+// dot-segments are resolved, and an empty path becomes "/".
+// The userinfo and fragment are dropped, as they are never part of a gemini request.
+func (r *Request) Key() string {
+	u := r.URL
+	var b strings.Builder
+
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "" {
+		b.WriteString(scheme)
+		b.WriteByte(':')
+	}
+	if u.Host != "" || scheme != "" {
+		b.WriteString("//")
+		host := NormalizeHost(u.Hostname())
+		if strings.Contains(host, ":") { // ipv6
+			host = "[" + host + "]"
+		}
+		b.WriteString(host)
+		if port := u.Port(); port != "" && !(port == DefaultPort && (scheme == "gemini" || scheme == "")) {
+			b.WriteByte(':')
+			b.WriteString(port)
+		}
+	}
+
+	p := removeDotSegments(normalizePercent(u.EscapedPath()))
+	if p == "" {
+		p = "/"
+	}
+	b.WriteString(p)
+
+	if u.RawQuery != "" || u.ForceQuery {
+		b.WriteByte('?')
+		b.WriteString(normalizePercent(u.RawQuery))
+	}
+	return b.String()
+}
+
+// Normalized returns a new request parsed from Key()
+func (r *Request) Normalized() (*Request, error) {
+	return ParseRequest(r.Key())
+}
+
+// Equivalent returns true if both requests refer to the same resource, see Key()
+func (r *Request) Equivalent(o *Request) bool {
+	return r.Key() == o.Key()
+}
+
+// normalizePercent uppercases percent-encodings, and decodes the ones that encode unreserved characters
+func normalizePercent(s string) string {
+	if strings.IndexByte(s, '%') < 0 {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			b.WriteByte(s[i])
+			continue
+		}
+		c := unhex(s[i+1])<<4 | unhex(s[i+2])
+		if isUnreserved(c) {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteByte(upperHex(s[i+1]))
+			b.WriteByte(upperHex(s[i+2]))
+		}
+		i += 2
+	}
+	return b.String()
+}
+
+// removeDotSegments implements RFC 3986 section 5.2.4
+func removeDotSegments(in string) string {
+	var out []string // output segments, each starting with a /
+	for in != "" {
+		switch {
+		case strings.HasPrefix(in, "../"):
+			in = in[3:]
+		case strings.HasPrefix(in, "./"):
+			in = in[2:]
+		case strings.HasPrefix(in, "/./"):
+			in = in[2:]
+		case in == "/.":
+			in = "/"
+		case strings.HasPrefix(in, "/../"):
+			in = in[3:]
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		case in == "/..":
+			in = "/"
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		case in == "." || in == "..":
+			in = ""
+		default:
+			i := strings.IndexByte(in[1:], '/') + 1
+			if i == 0 {
+				i = len(in)
+			}
+			out = append(out, in[:i])
+			in = in[i:]
+		}
+	}
+	return strings.Join(out, "")
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	}
+	return c - 'A' + 10
+}
+
+func upperHex(c byte) byte {
+	if 'a' <= c && c <= 'f' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
@@ -0,0 +1,44 @@
+package gemini_test
+
+import (
+	"testing"
+
+	"toast.cafe/x/gemini"
+)
+
+func TestRequestKey(t *testing.T) {
+	for _, v := range []struct {
+		req    string
+		expect string
+	}{
+		{"GEMINI://Example.ORG:1965", "gemini://example.org/"},
+		{"gemini://example.org:1966/", "gemini://example.org:1966/"},
+		{"gemini://example.org/a/./b/../c", "gemini://example.org/a/c"},
+		{"gemini://example.org/../../a", "gemini://example.org/a"},
+		{"gemini://example.org/a/%2e%2E/b", "gemini://example.org/b"},
+		{"gemini://example.org/%7euser/%2f%c3%a9", "gemini://example.org/~user/%2F%C3%A9"},
+		{"gemini://example.org/?q%3f%41", "gemini://example.org/?q%3FA"},
+		{"gemini://user@bücher.example/#frag", "gemini://xn--bcher-kva.example/"},
+		{"gemini://[::1]:1965/", "gemini://[::1]/"},
+	} {
+		r, err := gemini.ParseRequest(v.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Key() != v.expect {
+			t.Errorf("%q: expected %q, instead found %q", v.req, v.expect, r.Key())
+		}
+	}
+}
+
+func TestRequestEquivalent(t *testing.T) {
+	r1, _ := gemini.ParseRequest("gemini://example.org:1965/a/../%7Eb")
+	r2, _ := gemini.ParseRequest("gemini://EXAMPLE.org/~b")
+	r3, _ := gemini.ParseRequest("gemini://example.org/~b/")
+	if !r1.Equivalent(r2) {
+		t.Errorf("expected %q and %q to be equivalent", r1, r2)
+	}
+	if r1.Equivalent(r3) {
+		t.Errorf("expected %q and %q not to be equivalent", r1, r3)
+	}
+}
@@ -1,8 +1,6 @@
 package gemini
 
 import (
-	"bytes"
-	"fmt"
 	"io"
 	"net/url"
 	"path"
@@ -34,6 +32,7 @@ func (r *Request) Canonicalize() bool {
 	// disallowed components
 	u.Opaque = ""
 	u.User = nil
+	u.Fragment = "" // never sent
 
 	// irrecoverable
 	if u.Hostname() == "" || // authority section is required
@@ -41,36 +40,50 @@ func (r *Request) Canonicalize() bool {
 		return false
 	}
 
+	// hosts are sent in their ascii form
+	if r.NormalizeHost() != nil {
+		return false
+	}
+
 	// apply defaults
 	if u.Scheme == "" {
 		u.Scheme = "gemini"
 	}
 	if u.Port() == "" {
-		u.Host += ":1965"
+		u.Host += ":" + DefaultPort
 	}
 
 	return true
 }
 
 // ReadRequest constructs a request from a reader, and expects a \r\n
+//
+// The request line is read incrementally, so it may arrive across several reads.
+// If r is a *bufio.Reader, nothing past the \r\n is consumed, otherwise data past it may be lost.
 func ReadRequest(r io.Reader) (*Request, error) {
-	// we can over-read because there is no request body in gemini
-	buf := make([]byte, MaxURL+2) // \r\n
-	_, e1 := r.Read(buf)          // io.Reader says we should process n before looking at errors
-
-	l := bytes.Index(buf, []byte("\r\n"))
-	if l < 0 {
-		return nil, fmt.Errorf("%w: no \\r\\n in %d bytes", ErrRequest, MaxURL+2)
-	}
+	return readRequest(r, ParseRequest)
+}
 
-	u := buf[:l] // the url without the \r\n
-	runtime.KeepAlive(u)
-	rr, e2 := ParseRequest(*(*string)(unsafe.Pointer(&u)))
+// ReadRequestStrict is like ReadRequest, but validates the request with ValidateRequest
+func ReadRequestStrict(r io.Reader) (*Request, error) {
+	return readRequest(r, ValidateRequest)
+}
 
-	if e1 != nil {
-		return rr, e1
+func readRequest(r io.Reader, parse func(string) (*Request, error)) (*Request, error) {
+	br := bufReader(r, MaxURL+2) // \r\n
+	buf, err := readLine(br, MaxURL+2)
+	switch err {
+	case nil:
+	case errLineTooLong:
+		return nil, ErrRequestTooLong
+	case errLineTruncated:
+		return nil, ErrRequestTruncated
+	default:
+		return nil, err
 	}
-	return rr, e2
+
+	runtime.KeepAlive(buf)
+	return parse(*(*string)(unsafe.Pointer(&buf))) // buf is our own copy
 }
 
 // ParseRequest constructs a request from a string without an \r\n
@@ -81,8 +94,12 @@ func ParseRequest(s string) (*Request, error) {
 	return &out, e
 }
 
+// Host returns the normalized hostname of the request, without the port
+//
+// Unicode and punycode spellings of the same host result in the same string, see NormalizeHost.
+// Use UnicodeHost for display.
 func (r *Request) Host() string {
-	return r.URL.Hostname()
+	return NormalizeHost(r.URL.Hostname())
 }
 
 func (r *Request) Path() string {
@@ -1,9 +1,12 @@
 package gemini_test
 
 import (
+	"bufio"
+	"io/ioutil"
 	"net/url"
 	"strings"
 	"testing"
+	"testing/iotest"
 
 	"toast.cafe/x/gemini"
 )
@@ -26,3 +29,31 @@ func TestReadRequest(t *testing.T) {
 	}
 
 }
+
+func TestReadRequestShort(t *testing.T) {
+	rdr := iotest.OneByteReader(strings.NewReader("gemini://some.host/path\r\n"))
+	r, err := gemini.ReadRequest(rdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.String() != "gemini://some.host/path" {
+		t.Errorf("expected %q, instead found %q", "gemini://some.host/path", r.String())
+	}
+
+	_, err = gemini.ReadRequest(strings.NewReader("gemini://some.host/path"))
+	if err != gemini.ErrRequestTruncated {
+		t.Errorf("expected error %q, instead found %q", gemini.ErrRequestTruncated, err)
+	}
+}
+
+// a buffered reader keeps whatever comes after the request
+func TestReadRequestBuffered(t *testing.T) {
+	br := bufio.NewReader(strings.NewReader("gemini://some.host/\r\nrest"))
+	if _, err := gemini.ReadRequest(br); err != nil {
+		t.Fatal(err)
+	}
+	rest, _ := ioutil.ReadAll(br)
+	if string(rest) != "rest" {
+		t.Errorf("expected %q, instead found %q", "rest", rest)
+	}
+}
@@ -1,7 +1,7 @@
 package gemini
 
 import (
-	//"bufio"
+	"bufio"
 	"bytes"
 	"fmt"
 	"io"
@@ -71,17 +71,20 @@ type Response struct {
 	flushed bool // called Flush()?
 	reader  io.Reader
 	writer  bytesWriter
+
+	// streaming, see Commit()
+	conn      io.Writer     // where to stream to, nil if not streamable
+	stream    *bufio.Writer // wraps conn once committed
+	committed []byte        // the header that was sent, nil if not committed
+	err       error         // sticky stream error
 }
 
 // NewResponse is a response initializer for use by servers
 //
 // Note: your meta should not include the terminating \r\n.
 func NewResponse(status Status, meta string) (*Response, error) {
-	if len(meta) > 1024 {
-		return nil, fmt.Errorf("%w: meta length > 1024 bytes", ErrHeader)
-	}
-	if status < 10 || status >= 100 {
-		return nil, fmt.Errorf("%w: invalid response (%d)", ErrHeader, status)
+	if err := validHeader(status, meta); err != nil {
+		return nil, err
 	}
 	var r Response
 
@@ -103,6 +106,62 @@ func (r *Response) ServerPrepare() {
 	r.writer = &builder
 }
 
+// ServerPrepareStream prepares a response for usage by a server, allowing it to be streamed to w
+//
+// The response is buffered like with ServerPrepare until Commit is called.
+func (r *Response) ServerPrepareStream(w io.Writer) {
+	r.ServerPrepare()
+	r.conn = w
+}
+
+// SetHeader sets the status and meta of the response
+//
+// Unlike setting them directly, it validates them, and returns ErrCommitted if the header was already sent.
+func (r *Response) SetHeader(status Status, meta string) error {
+	if r.committed != nil {
+		return fmt.Errorf("%w: cannot set header to %d %s", ErrCommitted, status, meta)
+	}
+	if err := validHeader(status, meta); err != nil {
+		return err
+	}
+	r.Status = status
+	r.SetMeta(meta)
+	return nil
+}
+
+// Commit sends the header and everything written so far, switching the response to streaming
+//
+// Once committed, Write goes straight to the underlying connection (through a buffer, see Flush), and the header can no longer be changed.
+// Commit is only valid for responses prepared with ServerPrepareStream, other responses result in ErrStream.
+func (r *Response) Commit() error {
+	switch {
+	case r.conn == nil:
+		return ErrStream
+	case r.committed != nil:
+		return fmt.Errorf("%w: already committed", ErrCommitted)
+	}
+	if err := validHeader(r.Status, r.Meta()); err != nil {
+		return err
+	}
+
+	r.committed = r.Header()
+	r.stream = bufio.NewWriter(r.conn)
+	r.stream.Write(r.committed)
+	r.stream.Write(r.writer.Bytes())
+	r.err = r.stream.Flush()
+	return r.err
+}
+
+// Committed returns true if the header has already been sent, see Commit()
+func (r *Response) Committed() bool {
+	return r.committed != nil
+}
+
+// Err returns the first error encountered while streaming, if any
+func (r *Response) Err() error {
+	return r.err
+}
+
 // Reset resets the response to be reused
 func (r *Response) Reset() {
 Reset: // do we need to reset anything?
@@ -119,6 +178,21 @@ Reset: // do we need to reset anything?
 	case r.read:
 		r.read = false
 		goto Reset
+	case r.flushed:
+		r.flushed = false
+		goto Reset
+	case r.conn != nil:
+		r.conn = nil
+		goto Reset
+	case r.stream != nil:
+		r.stream = nil
+		goto Reset
+	case r.committed != nil:
+		r.committed = nil
+		goto Reset
+	case r.err != nil:
+		r.err = nil
+		goto Reset
 	case r.reader != nil:
 		r.reader = nil
 		goto Reset
@@ -130,39 +204,42 @@ Reset: // do we need to reset anything?
 // FromReader populates a response from a reader
 //
 // The reader should include the header line - this is meant to be used by clients.
+// The header is read incrementally until the \r\n, so it may arrive across several reads.
+// Everything after the header is left for Read() and Body(), nothing is dropped.
 func (r *Response) FromReader(reader io.Reader) error {
-	buf := make([]byte, MaxMeta+5) // exact size of the largest valid header
+	br := bufReader(reader, MaxMeta+5) // exact size of the largest valid header
+	buf, err := readLine(br, MaxMeta+5)
+	switch err {
+	case nil:
+	case errLineTooLong:
+		return fmt.Errorf("%w: no \\r\\n in %d bytes", ErrHeaderTooLong, MaxMeta+5)
+	case errLineTruncated:
+		return fmt.Errorf("%w: %d bytes without a \\r\\n", ErrHeaderTruncated, len(buf))
+	default:
+		return err
+	}
 
-	n, err := reader.Read(buf) // io.Reader says we should process n before looking at errors
-	if n < 4 {                 // for type safety below, cases where crlf is too far we catch elsewhere
-		return fmt.Errorf("%w: not enough data in the reader for a valid header", ErrHeader)
+	if len(buf) < 3 {
+		return fmt.Errorf("%w: %q is too short for a valid header", ErrHeader, buf)
 	}
 
 	// read status
+	if !isDigit(buf[0]) || !isDigit(buf[1]) {
+		return fmt.Errorf("%w: status corrupted: %q", ErrHeader, buf[:2])
+	}
 	r.Status = fastAtoi(buf[:2])
-	if r.Status > 99 || r.Status < 10 {
+	if r.Status < 10 {
 		return fmt.Errorf("%w: status corrupted: %d", ErrHeader, r.Status)
 	}
 	if buf[2] != ' ' {
 		return fmt.Errorf("%w: %c is not a space", ErrHeader, buf[2])
 	}
 
-	// read meta
-	delim := bytes.Index(buf[3:n], []byte("\r\n")) + 3
-	if delim == 2 { // -1 + 3 = 2
-		return fmt.Errorf("%w: no \\r\\n in %d bytes", ErrHeader, MaxMeta+5)
-	}
-
-	r.meta = buf[3:delim]
-	delim += 2 // skip \r\n
+	// read meta, buf is our own copy
+	r.meta = buf[3:]
 
 	// everything else is the body
-	// technically I could do my own buffering and avoid a copy later, but I'm lazy
-	r.reader = io.MultiReader(bytes.NewReader(buf[delim:n]), reader) // io.MultiReader does a copy
-
-	if err != io.EOF {
-		return err
-	}
+	r.reader = br
 	return nil
 }
 
@@ -176,16 +253,28 @@ func (r *Response) Read(b []byte) (int, error) {
 }
 
 // Write allows you to stream the body into the response
-func (r *Response) Write(b []byte) (int, error) {
-	if r.flushed {
+func (r *Response) Write(b []byte) (n int, err error) {
+	switch {
+	case r.err != nil:
+		return 0, r.err
+	case r.committed != nil:
+		n, r.err = r.stream.Write(b)
+		return n, r.err
+	case r.flushed:
 		return 0, ErrFlush
 	}
 	return r.writer.Write(b)
 }
 
 // WriteString allows you to stream the body into the response
-func (r *Response) WriteString(s string) (int, error) {
-	if r.flushed {
+func (r *Response) WriteString(s string) (n int, err error) {
+	switch {
+	case r.err != nil:
+		return 0, r.err
+	case r.committed != nil:
+		n, r.err = r.stream.WriteString(s)
+		return n, r.err
+	case r.flushed:
 		return 0, ErrFlush
 	}
 	return r.writer.WriteString(s)
@@ -194,7 +283,19 @@ func (r *Response) WriteString(s string) (int, error) {
 // Flush flushes the writer from Write() into the body, making Body() callable
 //
 // Note that once Flushed, you can no longer call Write(), but gain the ability to call Read()
+//
+// Committed responses are instead flushed to the underlying connection, and can keep being written to.
+// Errors, including changes to the header after it was committed, are available from Err().
 func (r *Response) Flush() {
+	if r.committed != nil {
+		if r.err == nil {
+			r.err = r.stream.Flush()
+		}
+		if r.err == nil && !bytes.Equal(r.committed, r.Header()) {
+			r.err = fmt.Errorf("%w: header changed to %d %s after being sent", ErrCommitted, r.Status, r.Meta())
+		}
+		return
+	}
 	r.flushed = true
 	r.body = r.writer.Bytes()
 	r.reader = bytes.NewReader(r.body)
@@ -223,6 +324,20 @@ BodyRet:
 
 // ---- util
 
+func validHeader(status Status, meta string) error {
+	if len(meta) > MaxMeta {
+		return fmt.Errorf("%w: meta length > %d bytes", ErrHeader, MaxMeta)
+	}
+	if status < 10 || status >= 100 {
+		return fmt.Errorf("%w: invalid response (%d)", ErrHeader, status)
+	}
+	return nil
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
 // read-only atoi version that runs against [2]byte and doesn't allocate
 // meant to be inlined, no warnings on failure so make sure output is sane
 func fastAtoi(b []byte) Status {
@@ -251,3 +251,91 @@ func BenchmarkReadResponse(b *testing.B) {
 		b.StartTimer()
 	}
 }
+
+// ---- streaming
+
+func TestStreamResponse(t *testing.T) {
+	var conn bytes.Buffer
+	var r gemini.Response
+	r.ServerPrepareStream(&conn)
+
+	r.SetHeader(gemini.StatusSuccess, "text/plain")
+	r.WriteString("buffered ")
+	if conn.Len() != 0 {
+		t.Errorf("expected nothing to be sent before committing, instead found %q", conn.String())
+	}
+	if err := r.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	r.WriteString("streamed")
+	r.Flush()
+
+	if expect := "20 text/plain\r\nbuffered streamed"; conn.String() != expect {
+		t.Errorf("expected %q, instead found %q", expect, conn.String())
+	}
+	if err := r.SetHeader(gemini.StatusNotFound, ""); !errors.Is(err, gemini.ErrCommitted) {
+		t.Errorf("expected error %q, instead found %q", gemini.ErrCommitted, err)
+	}
+
+	r.Status = gemini.StatusNotFound // too late
+	r.Flush()
+	if !errors.Is(r.Err(), gemini.ErrCommitted) {
+		t.Errorf("expected error %q, instead found %q", gemini.ErrCommitted, r.Err())
+	}
+}
+
+func TestCommitBuffered(t *testing.T) {
+	r, _ := gemini.NewResponse(gemini.StatusSuccess, "")
+	if err := r.Commit(); !errors.Is(err, gemini.ErrStream) {
+		t.Errorf("expected error %q, instead found %q", gemini.ErrStream, err)
+	}
+}
+
+// ---- short reads
+
+// chunkReader returns its data in fixed-size chunks, like separate tls records
+type chunkReader struct {
+	data []byte
+	size int
+}
+
+func (c *chunkReader) Read(b []byte) (int, error) {
+	if len(c.data) == 0 {
+		return 0, io.EOF
+	}
+	n := c.size
+	if n > len(c.data) {
+		n = len(c.data)
+	}
+	n = copy(b, c.data[:n])
+	c.data = c.data[n:]
+	return n, nil
+}
+
+func TestReadResponseShort(t *testing.T) {
+	for _, v := range testresp {
+		for _, size := range []int{1, 3, 7} {
+			var r gemini.Response
+			err := r.FromReader(&chunkReader{combine(v.status, v.meta, v.body), size})
+			v.Test(t, &r, err)
+		}
+	}
+}
+
+func TestReadResponseTruncated(t *testing.T) {
+	for _, v := range []struct {
+		raw string
+		err error
+	}{
+		{"20 text/gemini", gemini.ErrHeaderTruncated},
+		{"", gemini.ErrHeaderTruncated},
+		{"20 " + string(randMeta(gemini.MaxMeta+10)), gemini.ErrHeaderTooLong},
+		{"20\r\n", gemini.ErrHeader},
+	} {
+		var r gemini.Response
+		err := r.FromReader(&chunkReader{[]byte(v.raw), 2})
+		if !errors.Is(err, v.err) || !errors.Is(err, gemini.ErrHeader) {
+			t.Errorf("expected error %q, instead found %q", v.err, err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package spartan
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/url"
+	"strings"
+
+	"toast.cafe/x/gemini"
+)
+
+// Client is a spartan client
+//
+// Responses populate a gemini.Ctx, with the status translated by ToGemini.
+type Client struct {
+	Dialer net.Dialer
+
+	// FailureErrors makes Do return a *gemini.StatusError for failure statuses.
+	//
+	// The context is populated regardless.
+	FailureErrors bool
+}
+
+// DefaultClient is the default
+var DefaultClient = &Client{}
+
+// Do performs the spartan request in the context, populating it
+//
+// The request body is ctx.Body if set, the request input (see gemini.Request.Input) otherwise.
+func (c *Client) Do(ctx *gemini.Ctx) error {
+	u := ctx.Req.URL
+	if u.Scheme != "spartan" || u.Host == "" {
+		return fmt.Errorf("%w: expected an absolute spartan url", ErrRequest)
+	}
+	host := ctx.Req.Host()
+	port := u.Port()
+	if port == "" {
+		port = DefaultPort
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	var body []byte
+	var err error
+	if ctx.Body != nil {
+		body, err = ioutil.ReadAll(ctx.Body)
+	} else if ctx.Req.HasInput() {
+		var input string
+		input, err = ctx.Req.Input()
+		body = []byte(input)
+	}
+	if err != nil {
+		return err
+	}
+
+	con, err := c.Dialer.Dial("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(con, "%s %s %d\r\n", host, path, len(body)); err != nil {
+		con.Close()
+		return err
+	}
+	if _, err := con.Write(body); err != nil {
+		con.Close()
+		return err
+	}
+
+	// receive response, the header can't be longer than a gemini one
+	lr := &io.LimitedReader{R: con, N: maxHeader}
+	br := bufio.NewReader(lr)
+	status, meta, err := readHeader(br, lr)
+	if err != nil {
+		con.Close()
+		return err
+	}
+	lr.N = math.MaxInt64 // the body is unbounded
+	// let gemini parse the translated header, so that the response behaves as usual
+	header := strings.NewReader(fmt.Sprintf("%d %s\r\n", ToGemini(status), meta))
+	ctx.Res = new(gemini.Response)
+	err = ctx.Res.FromReader(io.MultiReader(header, br))
+	if err == nil && c.FailureErrors && ctx.Status().IsFailure() {
+		return gemini.NewStatusError(ctx.Status(), ctx.Meta())
+	}
+	return err
+}
+
+// Fetch parses your request and returns a populated context
+//
+// The scheme may be omitted, in which case spartan is assumed.
+func (c *Client) Fetch(req string) (*gemini.Ctx, error) {
+	if !strings.Contains(req, "://") {
+		req = "spartan://" + req
+	}
+	u, err := url.Parse(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrRequest, err)
+	}
+	ctx := &gemini.Ctx{Req: &gemini.Request{URL: u}}
+	return ctx, c.Do(ctx)
+}
+
+// Submit sends input to target, returning a populated context
+func (c *Client) Submit(target string, input string) (*gemini.Ctx, error) {
+	if !strings.Contains(target, "://") {
+		target = "spartan://" + target
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrRequest, err)
+	}
+	ctx := &gemini.Ctx{Req: &gemini.Request{URL: u}, Body: strings.NewReader(input)}
+	return ctx, c.Do(ctx)
+}
+
+// the longest valid header, "d meta\r\n"
+const maxHeader = gemini.MaxMeta + 4
+
+// readHeader reads a "status meta\r\n" spartan header from br, which reads from lr
+func readHeader(br *bufio.Reader, lr *io.LimitedReader) (Status, string, error) {
+	line, err := br.ReadString('\n')
+	if err != nil && lr.N == 0 {
+		return 0, "", fmt.Errorf("%w: no \\r\\n in %d bytes", ErrHeader, maxHeader)
+	} else if err != nil {
+		return 0, "", fmt.Errorf("%w: truncated", ErrHeader)
+	}
+	line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+	if len(line) < 1 || line[0] < '2' || line[0] > '5' || (len(line) > 1 && line[1] != ' ') {
+		return 0, "", fmt.Errorf("%w: %q", ErrHeader, line)
+	}
+	status := Status(line[0] - '0')
+	if len(line) > 1 {
+		return status, line[2:], nil
+	}
+	return status, "", nil
+}
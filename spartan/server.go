@@ -0,0 +1,165 @@
+package spartan
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/gms"
+)
+
+// DefaultMaxBody is the largest request body a Server accepts by default
+const DefaultMaxBody = 64 * 1024
+
+// Server is a spartan server serving gms handlers
+//
+// Requests reach the handler as a gemini.Ctx with a spartan:// request.
+// The request body, if any, is exposed both as the request query (see gemini.Request.Input) and as Ctx.Body.
+// Responses are translated with FromGemini, and input statuses become a text/gemini page with an input prompt line.
+// Responses are always buffered, as committing a gemini header makes no sense here.
+type Server struct {
+	// TCP address to listen on, defaults to :300
+	Addr    string
+	Handler gms.Handler
+	Logger  gms.Logger
+
+	// MaxBody is the largest request body accepted, DefaultMaxBody if zero.
+	MaxBody int
+}
+
+func (s *Server) log(fmt string, args ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Printf(fmt, args...)
+	}
+}
+
+// Serve listens on Addr and serves requests
+func (s *Server) Serve() error {
+	addr := s.Addr
+	if addr == "" {
+		addr = ":" + DefaultPort
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.ServeListener(l)
+}
+
+// ServeListener serves requests from an existing listener
+func (s *Server) ServeListener(l net.Listener) error {
+	return gms.ServeListener(l, s, s.Logger)
+}
+
+// ServeConn serves a single request from c, and closes it
+func (s *Server) ServeConn(c net.Conn) {
+	defer c.Close()
+	lr := &io.LimitedReader{R: c, N: gms.DefaultMaxLine}
+	br := bufio.NewReader(lr)
+
+	ctx, err := s.readRequest(br, lr)
+	if err != nil {
+		fmt.Fprintf(c, "%d %s\r\n", StatusClientError, err)
+		return
+	}
+
+	res, _ := gemini.NewResponse(gemini.StatusSuccess, "")
+	ctx.Res = res
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				s.log("panic while handling connection: %s", r)
+				ctx.Res, _ = gemini.NewResponse(gemini.StatusTemporaryFailure, "internal error")
+			}
+		}()
+		s.Handler.ServeGem(ctx)
+	}()
+	ctx.Res.Flush()
+
+	s.writeResponse(c, ctx)
+}
+
+// readRequest parses "host path length\r\n" and the body
+//
+// br reads from lr, which bounds the request line to gms.DefaultMaxLine bytes, and is extended by the body length once known.
+func (s *Server) readRequest(br *bufio.Reader, lr *io.LimitedReader) (*gemini.Ctx, error) {
+	line, err := br.ReadString('\n')
+	if err != nil && lr.N == 0 {
+		return nil, fmt.Errorf("%w: request line too long", ErrRequest)
+	} else if err != nil {
+		return nil, fmt.Errorf("%w: truncated request line", ErrRequest)
+	}
+	fields := strings.Split(strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), " ")
+	if len(fields) != 3 || fields[0] == "" || !strings.HasPrefix(fields[1], "/") {
+		return nil, fmt.Errorf("%w: expected \"host path length\"", ErrRequest)
+	}
+	length, err := strconv.Atoi(fields[2])
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("%w: invalid length", ErrRequest)
+	}
+	max := s.MaxBody
+	if max <= 0 {
+		max = DefaultMaxBody
+	}
+	if length > max {
+		return nil, fmt.Errorf("%w: body too large", ErrRequest)
+	}
+
+	u, err := url.Parse(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid path", ErrRequest)
+	}
+	u.Scheme = "spartan"
+	u.Host = fields[0]
+
+	ctx := new(gemini.Ctx)
+	ctx.Req = &gemini.Request{URL: u}
+	if length > 0 {
+		lr.N += int64(length)
+		body := make([]byte, length)
+		if _, err := io.ReadFull(br, body); err != nil {
+			return nil, fmt.Errorf("%w: truncated body", ErrRequest)
+		}
+		u.RawQuery = gemini.EscapeInput(string(body)) // input semantics match gemini's
+		ctx.Body = bytes.NewReader(body)
+	}
+	return ctx, nil
+}
+
+// writeResponse translates the gemini response in ctx to spartan
+func (s *Server) writeResponse(w io.Writer, ctx *gemini.Ctx) {
+	status := ctx.Status()
+	meta := ctx.Meta()
+
+	switch {
+	case status.IsInput():
+		// spartan prompts for input with a link line instead
+		fmt.Fprintf(w, "%d text/gemini\r\n=: %s %s\n", StatusSuccess, ctx.Req.URL.EscapedPath(), meta)
+		return
+	case status.IsSuccess() && meta == "":
+		meta = gemini.DefaultMediaType
+	case status.IsRedirect():
+		// spartan redirects are paths on the same host
+		if u, err := ctx.Req.URL.Parse(meta); err == nil && (u.Host == "" || u.Host == ctx.Req.URL.Host) {
+			meta = u.EscapedPath()
+			if u.RawQuery != "" {
+				meta += "?" + u.RawQuery
+			}
+		} else {
+			status, meta = gemini.StatusProxyRequestRefused, "cannot redirect to another host"
+		}
+	}
+
+	fmt.Fprintf(w, "%d %s\r\n", FromGemini(status), meta)
+	if status.IsSuccess() {
+		body, _ := ioutil.ReadAll(ctx.Res)
+		w.Write(body)
+	}
+}
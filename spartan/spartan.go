@@ -0,0 +1,62 @@
+// Package spartan implements the spartan protocol on top of the gemini machinery.
+//
+// Spartan is a plain TCP protocol: the client sends "host path length\r\n" followed by a body of that length,
+// and the server answers with a single digit status, a meta and a body.
+// Servers reuse gms.Handler, and clients populate a gemini.Ctx, with statuses translated in both directions.
+// Spartan has no input status: input is carried by the request body, which is mapped onto the request query.
+package spartan
+
+import "toast.cafe/x/gemini"
+
+type spartanError string
+
+func (e spartanError) Error() string { return string(e) }
+
+// Spartan errors
+const (
+	ErrHeader  = spartanError("invalid spartan header")
+	ErrRequest = spartanError("invalid spartan request")
+)
+
+// Status represents a spartan status code
+type Status int
+
+// Spartan status codes
+const (
+	StatusSuccess     Status = 2
+	StatusRedirect    Status = 3
+	StatusClientError Status = 4
+	StatusServerError Status = 5
+)
+
+// DefaultPort is the default spartan port
+const DefaultPort = "300"
+
+// FromGemini maps a gemini status onto the closest spartan status
+//
+// Input statuses have no spartan equivalent, servers answer them with an input prompt document instead (see Server).
+// They map to StatusSuccess.
+func FromGemini(s gemini.Status) Status {
+	switch {
+	case s.IsInput(), s.IsSuccess():
+		return StatusSuccess
+	case s.IsRedirect():
+		return StatusRedirect
+	case s.IsTemporaryFailure():
+		return StatusServerError
+	}
+	return StatusClientError // permanent failures, certificates, and garbage
+}
+
+// ToGemini maps a spartan status onto the default gemini status of the matching class
+func ToGemini(s Status) gemini.Status {
+	switch s {
+	case StatusSuccess:
+		return gemini.StatusSuccess
+	case StatusRedirect:
+		return gemini.StatusRedirectTemporary
+	case StatusServerError:
+		return gemini.StatusTemporaryFailure
+	}
+	return gemini.StatusPermanentFailure
+}
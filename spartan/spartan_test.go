@@ -0,0 +1,133 @@
+package spartan_test
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"toast.cafe/x/gemini"
+	"toast.cafe/x/gemini/gms"
+	"toast.cafe/x/gemini/spartan"
+)
+
+func TestStatusMapping(t *testing.T) {
+	for _, tc := range []struct {
+		gemini  gemini.Status
+		spartan spartan.Status
+	}{
+		{gemini.StatusInput, spartan.StatusSuccess},
+		{gemini.StatusSuccess, spartan.StatusSuccess},
+		{gemini.StatusRedirectPermanent, spartan.StatusRedirect},
+		{gemini.StatusSlowDown, spartan.StatusServerError},
+		{gemini.StatusNotFound, spartan.StatusClientError},
+		{gemini.StatusClientCertificateRequires, spartan.StatusClientError},
+	} {
+		if got := spartan.FromGemini(tc.gemini); got != tc.spartan {
+			t.Errorf("FromGemini(%d) = %d, want %d", tc.gemini, got, tc.spartan)
+		}
+		if got := spartan.ToGemini(tc.spartan).Class(); tc.gemini.Class() != gemini.StatusInput &&
+			tc.gemini.Class() != gemini.StatusClientCertificateRequires && got != tc.gemini.Class() {
+			t.Errorf("ToGemini(%d) = %d, want class %d", tc.spartan, got, tc.gemini.Class())
+		}
+	}
+}
+
+func serve(t *testing.T, h gms.HandlerFunc) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen:", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go (&spartan.Server{Handler: h}).ServeListener(l)
+	return "spartan://" + l.Addr().String()
+}
+
+func TestRoundTrip(t *testing.T) {
+	base := serve(t, func(ctx *gemini.Ctx) {
+		switch ctx.Req.Path() {
+		case "/hello":
+			ctx.Res.SetMeta("text/plain")
+			ctx.Res.WriteString("hello " + ctx.Req.URL.Hostname())
+		case "/name":
+			if !ctx.Req.HasInput() {
+				ctx.Res.Status = gemini.StatusInput
+				ctx.Res.SetMeta("your name?")
+				return
+			}
+			name, _ := ctx.Req.Input()
+			ctx.Res.WriteString("hi " + name)
+		case "/old":
+			ctx.Res.Status = gemini.StatusRedirectPermanent
+			ctx.Res.SetMeta("gemini://" + ctx.Req.URL.Host + "/hello")
+		default:
+			ctx.Res.Status = gemini.StatusNotFound
+			ctx.Res.SetMeta("nope")
+		}
+	})
+
+	check := func(ctx *gemini.Ctx, err error, status gemini.Status, meta, body string) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ctx.Status() != status || ctx.Meta() != meta {
+			t.Errorf("got header %q, want %d %s", ctx.Header(), status, meta)
+		}
+		if got, _ := ctx.Res.Body(); got != body {
+			t.Errorf("got body %q, want %q", got, body)
+		}
+	}
+
+	c := spartan.DefaultClient
+	ctx, err := c.Fetch(base + "/hello")
+	check(ctx, err, gemini.StatusSuccess, "text/plain", "hello 127.0.0.1")
+
+	ctx, err = c.Fetch(base + "/name")
+	check(ctx, err, gemini.StatusSuccess, "text/gemini", "=: /name your name?\n")
+
+	ctx, err = c.Submit(base+"/name", "a b&c")
+	check(ctx, err, gemini.StatusSuccess, gemini.DefaultMediaType, "hi a b&c")
+
+	ctx, err = c.Fetch(base + "/name?x%20y")
+	check(ctx, err, gemini.StatusSuccess, gemini.DefaultMediaType, "hi x y")
+
+	ctx, err = c.Fetch(base + "/old")
+	check(ctx, err, gemini.StatusRedirectTemporary, "/hello", "")
+
+	ctx, err = c.Fetch(base + "/missing")
+	check(ctx, err, gemini.StatusPermanentFailure, "nope", "")
+}
+
+func TestLongLines(t *testing.T) {
+	base := serve(t, func(ctx *gemini.Ctx) {})
+	con, err := net.Dial("tcp", strings.TrimPrefix(base, "spartan://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+	go con.Write([]byte("host /" + strings.Repeat("a", gms.DefaultMaxLine)))
+	line, _ := bufio.NewReader(con).ReadString('\n')
+	if !strings.HasPrefix(line, "4 ") {
+		t.Errorf("expected a client error for a long request line, instead found %q", line)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen:", err)
+	}
+	defer l.Close()
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		bufio.NewReader(c).ReadString('\n')
+		c.Write([]byte("2 " + strings.Repeat("a", 2*gemini.MaxMeta)))
+	}()
+	if _, err := spartan.DefaultClient.Fetch("spartan://" + l.Addr().String() + "/"); !errors.Is(err, spartan.ErrHeader) {
+		t.Errorf("expected %q for a long header, instead found %v", spartan.ErrHeader, err)
+	}
+}
@@ -0,0 +1,115 @@
+package gemini
+
+import "fmt"
+
+var statusText = map[Status]string{
+	StatusInput:          "Input",
+	StatusInputSensitive: "Sensitive Input",
+
+	StatusSuccess: "Success",
+
+	StatusRedirectTemporary: "Temporary Redirect",
+	StatusRedirectPermanent: "Permanent Redirect",
+
+	StatusTemporaryFailure:  "Temporary Failure",
+	StatusServerUnavailable: "Server Unavailable",
+	StatusCGIError:          "CGI Error",
+	StatusProxyError:        "Proxy Error",
+	StatusSlowDown:          "Slow Down",
+
+	StatusPermanentFailure:    "Permanent Failure",
+	StatusNotFound:            "Not Found",
+	StatusGone:                "Gone",
+	StatusProxyRequestRefused: "Proxy Request Refused",
+	StatusBadRequest:          "Bad Request",
+
+	StatusClientCertificateRequires: "Client Certificate Required",
+	StatusCertificateNotAuthorized:  "Certificate Not Authorized",
+	StatusCertificateNotValid:       "Certificate Not Valid",
+}
+
+// Class returns the class of the status, as the default status of that class
+//
+// For example, the class of StatusNotFound is StatusPermanentFailure.
+func (s Status) Class() Status {
+	return s / 10 * 10
+}
+
+// Valid returns true if the status belongs to one of the classes defined by the spec
+func (s Status) Valid() bool {
+	return s >= 10 && s < 70
+}
+
+// Known returns true if the status is one of the codes defined by the spec
+func (s Status) Known() bool {
+	_, ok := statusText[s]
+	return ok
+}
+
+// Normalize maps unknown statuses to the default status of their class, as the spec says clients should
+//
+// It returns 0 for invalid statuses.
+func (s Status) Normalize() Status {
+	switch {
+	case !s.Valid():
+		return 0
+	case s.Known():
+		return s
+	}
+	return s.Class()
+}
+
+// IsInput returns true for 1x statuses
+func (s Status) IsInput() bool { return s.Class() == StatusInput }
+
+// IsSuccess returns true for 2x statuses
+func (s Status) IsSuccess() bool { return s.Class() == StatusSuccess }
+
+// IsRedirect returns true for 3x statuses
+func (s Status) IsRedirect() bool { return s.Class() == StatusRedirect }
+
+// IsTemporaryFailure returns true for 4x statuses
+func (s Status) IsTemporaryFailure() bool { return s.Class() == StatusTemporaryFailure }
+
+// IsPermanentFailure returns true for 5x statuses
+func (s Status) IsPermanentFailure() bool { return s.Class() == StatusPermanentFailure }
+
+// IsCertificate returns true for 6x statuses
+func (s Status) IsCertificate() bool { return s.Class() == StatusClientCertificateRequires }
+
+// IsFailure returns true for statuses that mean the request did not succeed: 4x, 5x and 6x
+func (s Status) IsFailure() bool {
+	return s.IsTemporaryFailure() || s.IsPermanentFailure() || s.IsCertificate()
+}
+
+// Text returns a human-readable description of the status
+//
+// Unknown statuses are described by their class, invalid statuses result in the empty string.
+func (s Status) Text() string {
+	return statusText[s.Normalize()]
+}
+
+// StatusError is an error carrying an unsuccessful response header
+//
+// It unwraps to ErrStatus.
+type StatusError struct {
+	Status Status
+	Meta   string
+}
+
+func (e *StatusError) Error() string {
+	if e.Meta == "" {
+		return fmt.Sprintf("%d %s", e.Status, e.Status.Text())
+	}
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Status.Text(), e.Meta)
+}
+
+// Unwrap returns ErrStatus
+func (e *StatusError) Unwrap() error {
+	return ErrStatus
+}
+
+// NewStatusError returns a StatusError for the given response header
+func NewStatusError(status Status, meta string) *StatusError {
+	return &StatusError{status, meta}
+}
@@ -0,0 +1,50 @@
+package gemini_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"toast.cafe/x/gemini"
+)
+
+func TestStatus(t *testing.T) {
+	for _, v := range []struct {
+		status    gemini.Status
+		class     gemini.Status
+		normal    gemini.Status
+		text      string
+		success   bool
+		redirect  bool
+		isFailure bool
+	}{
+		{gemini.StatusSuccess, gemini.StatusSuccess, gemini.StatusSuccess, "Success", true, false, false},
+		{25, gemini.StatusSuccess, gemini.StatusSuccess, "Success", true, false, false},
+		{gemini.StatusRedirectPermanent, gemini.StatusRedirect, gemini.StatusRedirectPermanent, "Permanent Redirect", false, true, false},
+		{gemini.StatusNotFound, gemini.StatusPermanentFailure, gemini.StatusNotFound, "Not Found", false, false, true},
+		{47, gemini.StatusTemporaryFailure, gemini.StatusTemporaryFailure, "Temporary Failure", false, false, true},
+		{63, gemini.StatusClientCertificateRequires, gemini.StatusClientCertificateRequires, "Client Certificate Required", false, false, true},
+		{75, 70, 0, "", false, false, false},
+	} {
+		s := v.status
+		if s.Class() != v.class || s.Normalize() != v.normal || s.Text() != v.text ||
+			s.IsSuccess() != v.success || s.IsRedirect() != v.redirect || s.IsFailure() != v.isFailure {
+			t.Errorf("%d: unexpected %d %d %q %t %t %t", s, s.Class(), s.Normalize(), s.Text(), s.IsSuccess(), s.IsRedirect(), s.IsFailure())
+		}
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	err := fmt.Errorf("fetching: %w", gemini.NewStatusError(gemini.StatusCertificateNotValid, "expired"))
+
+	var se *gemini.StatusError
+	if !errors.As(err, &se) || se.Status != gemini.StatusCertificateNotValid {
+		t.Errorf("expected a StatusError, instead found %v", err)
+	}
+	if !errors.Is(err, gemini.ErrStatus) {
+		t.Errorf("expected %q to wrap %q", err, gemini.ErrStatus)
+	}
+	if expect := "62 Certificate Not Valid: expired"; se.Error() != expect {
+		t.Errorf("expected %q, instead found %q", expect, se.Error())
+	}
+}
@@ -0,0 +1,170 @@
+package gemini
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TitanMime is the mime type of a titan upload that does not specify one
+const TitanMime = "text/gemini"
+
+// Titan request errors
+const (
+	ErrTitanScheme = RequestError("request is not a titan request")
+	ErrTitanSize   = RequestError("titan request has no valid size")
+	ErrTitanParam  = RequestError("titan request has an invalid parameter")
+)
+
+// TitanRequest is a titan upload request
+//
+// The embedded Request points at the resource being uploaded to, with the titan parameters stripped from the path.
+// String returns the full request, parameters included.
+type TitanRequest struct {
+	*Request
+
+	Size  int64  // the size of the body, in bytes
+	Mime  string // the mime type of the body, TitanMime if unspecified
+	Token string // optional authentication token
+
+	// Params holds all of the other parameters, percent-decoded.
+	Params map[string]string
+}
+
+// ParseTitanRequest parses a titan request string without the \r\n
+func ParseTitanRequest(s string) (*TitanRequest, error) {
+	r, err := ParseRequest(s)
+	if err != nil {
+		return nil, err
+	}
+	return r.Titan()
+}
+
+// NewTitanRequest builds a titan request uploading to target
+//
+// The target may use either the gemini or titan scheme.
+func NewTitanRequest(target string, size int64, mime, token string) (*TitanRequest, error) {
+	r, err := ParseRequest(target)
+	if err != nil {
+		return nil, err
+	}
+	if size < 0 {
+		return nil, ErrTitanSize
+	}
+	r.URL.Scheme = "titan"
+	if mime == "" {
+		mime = TitanMime
+	}
+	return &TitanRequest{Request: r, Size: size, Mime: mime, Token: token}, nil
+}
+
+// IsTitan returns true if the request uses the titan scheme
+func (r *Request) IsTitan() bool {
+	return strings.EqualFold(r.URL.Scheme, "titan")
+}
+
+// Titan interprets the request as a titan request, parsing its parameters
+func (r *Request) Titan() (*TitanRequest, error) {
+	if !r.IsTitan() {
+		return nil, ErrTitanScheme
+	}
+	p := r.URL.EscapedPath()
+	i := strings.IndexByte(p, ';')
+	if i < 0 {
+		return nil, ErrTitanSize
+	}
+
+	out := TitanRequest{Mime: TitanMime}
+	size := false
+	for _, kv := range strings.Split(p[i+1:], ";") {
+		eq := strings.IndexByte(kv, '=')
+		if eq < 0 {
+			return nil, ErrTitanParam
+		}
+		k := strings.ToLower(kv[:eq])
+		v, err := url.PathUnescape(kv[eq+1:])
+		if err != nil {
+			return nil, ErrTitanParam
+		}
+		switch k {
+		case "size":
+			out.Size, err = strconv.ParseInt(v, 10, 64)
+			if err != nil || out.Size < 0 {
+				return nil, ErrTitanSize
+			}
+			size = true
+		case "mime":
+			out.Mime = v
+		case "token":
+			out.Token = v
+		default:
+			if out.Params == nil {
+				out.Params = make(map[string]string)
+			}
+			out.Params[k] = v
+		}
+	}
+	if !size {
+		return nil, ErrTitanSize
+	}
+
+	base, err := url.Parse(p[:i])
+	if err != nil {
+		return nil, ErrTitanParam
+	}
+	u := *r.URL
+	u.Path, u.RawPath = base.Path, base.RawPath
+	out.Request = &Request{&u}
+	return &out, nil
+}
+
+// Target returns the gemini request for the resource being uploaded to
+func (t *TitanRequest) Target() *Request {
+	u := *t.URL
+	u.Scheme = "gemini"
+	return &Request{&u}
+}
+
+// String returns the full titan request, parameters included
+func (t *TitanRequest) String() string {
+	u := *t.URL
+	var b strings.Builder
+	b.WriteString(u.EscapedPath())
+	b.WriteString(";size=")
+	b.WriteString(strconv.FormatInt(t.Size, 10))
+	if t.Mime != "" && t.Mime != TitanMime {
+		b.WriteString(";mime=")
+		b.WriteString(escapeTitan(t.Mime))
+	}
+	if t.Token != "" {
+		b.WriteString(";token=")
+		b.WriteString(escapeTitan(t.Token))
+	}
+	keys := make([]string, 0, len(t.Params))
+	for k := range t.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys) // stable output
+	for _, k := range keys {
+		b.WriteByte(';')
+		b.WriteString(escapeTitan(k))
+		b.WriteByte('=')
+		b.WriteString(escapeTitan(t.Params[k]))
+	}
+
+	if t.HasInput() {
+		b.WriteByte('?')
+		b.WriteString(u.RawQuery)
+	}
+
+	// the path and query go in by hand
+	u.Path, u.RawPath, u.Opaque = "", "", ""
+	u.RawQuery, u.ForceQuery, u.Fragment = "", false, ""
+	return u.String() + b.String()
+}
+
+// escapes a titan parameter, keeping slashes for readable mime types
+func escapeTitan(s string) string {
+	return strings.ReplaceAll(url.PathEscape(s), "%2F", "/")
+}
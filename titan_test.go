@@ -0,0 +1,56 @@
+package gemini_test
+
+import (
+	"reflect"
+	"testing"
+
+	"toast.cafe/x/gemini"
+)
+
+func TestParseTitanRequest(t *testing.T) {
+	for _, v := range []struct {
+		req    string
+		path   string
+		size   int64
+		mime   string
+		token  string
+		params map[string]string
+		err    error
+	}{
+		{"titan://example.org/wiki/page;size=10;mime=text/plain;token=abc%20def", "/wiki/page", 10, "text/plain", "abc def", nil, nil},
+		{"titan://example.org/a;size=0", "/a", 0, gemini.TitanMime, "", nil, nil},
+		{"titan://example.org/a;size=5;x=y?q", "/a", 5, gemini.TitanMime, "", map[string]string{"x": "y"}, nil},
+		{"titan://example.org/a", "", 0, "", "", nil, gemini.ErrTitanSize},
+		{"titan://example.org/a;size=-1", "", 0, "", "", nil, gemini.ErrTitanSize},
+		{"titan://example.org/a;size=1;oops", "", 0, "", "", nil, gemini.ErrTitanParam},
+		{"gemini://example.org/a;size=1", "", 0, "", "", nil, gemini.ErrTitanScheme},
+	} {
+		r, err := gemini.ParseTitanRequest(v.req)
+		if err != v.err {
+			t.Errorf("%q: expected error %q, instead found %q", v.req, v.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if r.Path() != v.path || r.Size != v.size || r.Mime != v.mime || r.Token != v.token || !reflect.DeepEqual(r.Params, v.params) {
+			t.Errorf("%q: unexpected %q %d %q %q %v", v.req, r.Path(), r.Size, r.Mime, r.Token, r.Params)
+		}
+		if r.String() != v.req {
+			t.Errorf("%q: round trip resulted in %q", v.req, r.String())
+		}
+	}
+}
+
+func TestNewTitanRequest(t *testing.T) {
+	r, err := gemini.NewTitanRequest("gemini://example.org/page", 42, "text/plain", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := "titan://example.org/page;size=42;mime=text/plain;token=secret"; r.String() != expect {
+		t.Errorf("expected %q, instead found %q", expect, r.String())
+	}
+	if expect := "gemini://example.org/page"; r.Target().String() != expect {
+		t.Errorf("expected %q, instead found %q", expect, r.Target().String())
+	}
+}
@@ -0,0 +1,83 @@
+package gemini
+
+import (
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// RequestError is a reason for a request to be rejected by strict validation
+//
+// All RequestErrors match ErrRequest with errors.Is.
+// The reason is suitable for use as the meta of a StatusBadRequest response.
+type RequestError string
+
+func (e RequestError) Error() string { return string(e) }
+
+// Is makes RequestError match ErrRequest
+func (e RequestError) Is(target error) bool { return target == ErrRequest }
+
+// Request validation errors
+const (
+	ErrRequestTooLong   = RequestError("request is longer than 1024 bytes")
+	ErrRequestTruncated = RequestError("request ended before its \\r\\n")
+	ErrRequestBOM       = RequestError("request starts with a byte order mark")
+	ErrRequestUTF8      = RequestError("request is not valid utf-8")
+	ErrRequestParse     = RequestError("request is not a valid url")
+	ErrRequestRelative  = RequestError("request is a relative reference")
+	ErrRequestNoHost    = RequestError("request has no host")
+	ErrRequestUserinfo  = RequestError("request has userinfo")
+	ErrRequestFragment  = RequestError("request has a fragment")
+)
+
+// ValidateRequest parses a request string without the \r\n, rejecting anything the spec does not allow
+//
+// This is stricter than ParseRequest, which accepts anything url.Parse does.
+// The returned error is always a RequestError.
+func ValidateRequest(s string) (*Request, error) {
+	switch {
+	case len(s) > MaxURL:
+		return nil, ErrRequestTooLong
+	case strings.HasPrefix(s, "\ufeff"):
+		return nil, ErrRequestBOM
+	case !utf8.ValidString(s):
+		return nil, ErrRequestUTF8
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, ErrRequestParse
+	}
+	r := &Request{u}
+	if err := r.validURL(); err != nil {
+		return nil, err
+	}
+	// url.Parse drops an empty fragment, look for it by hand
+	if strings.IndexByte(s, '#') >= 0 {
+		return nil, ErrRequestFragment
+	}
+	return r, nil
+}
+
+// Validate checks that the request is suitable for sending
+//
+// It applies the same rules as ValidateRequest to the request as it would be sent.
+// Use Canonicalize first to fill in defaults and drop the parts a client should not send.
+func (r *Request) Validate() error {
+	_, err := ValidateRequest(r.String())
+	return err
+}
+
+func (r *Request) validURL() error {
+	u := r.URL
+	switch {
+	case !u.IsAbs():
+		return ErrRequestRelative
+	case u.Opaque != "" || u.Host == "" || u.Hostname() == "":
+		return ErrRequestNoHost
+	case u.User != nil:
+		return ErrRequestUserinfo
+	case u.Fragment != "":
+		return ErrRequestFragment
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package gemini_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"toast.cafe/x/gemini"
+)
+
+func TestValidateRequest(t *testing.T) {
+	long := "gemini://example.org/" + strings.Repeat("a", gemini.MaxURL)
+	for _, v := range []struct {
+		req string
+		err error
+	}{
+		{"gemini://example.org/path?query", nil},
+		{"gemini://example.org", nil},
+		{long, gemini.ErrRequestTooLong},
+		{"\ufeffgemini://example.org/", gemini.ErrRequestBOM},
+		{"gemini://example.org/\xff", gemini.ErrRequestUTF8},
+		{"gemini://exa mple.org/", gemini.ErrRequestParse},
+		{"//example.org/", gemini.ErrRequestRelative},
+		{"/path", gemini.ErrRequestRelative},
+		{"gemini:///path", gemini.ErrRequestNoHost},
+		{"gemini:opaque", gemini.ErrRequestNoHost},
+		{"gemini://user@example.org/", gemini.ErrRequestUserinfo},
+		{"gemini://example.org/#frag", gemini.ErrRequestFragment},
+		{"gemini://example.org/#", gemini.ErrRequestFragment},
+	} {
+		_, err := gemini.ValidateRequest(v.req)
+		if err != v.err {
+			t.Errorf("%q: expected error %q, instead found %q", v.req, v.err, err)
+		}
+		if err != nil && !errors.Is(err, gemini.ErrRequest) {
+			t.Errorf("%q: expected %q to match %q", v.req, err, gemini.ErrRequest)
+		}
+	}
+}
+
+func TestReadRequestStrict(t *testing.T) {
+	_, err := gemini.ReadRequestStrict(strings.NewReader("gemini://user@example.org/\r\n"))
+	if err != gemini.ErrRequestUserinfo {
+		t.Errorf("expected error %q, instead found %q", gemini.ErrRequestUserinfo, err)
+	}
+	long := strings.Repeat("a", gemini.MaxURL+2)
+	_, err = gemini.ReadRequestStrict(strings.NewReader(long))
+	if err != gemini.ErrRequestTooLong {
+		t.Errorf("expected error %q, instead found %q", gemini.ErrRequestTooLong, err)
+	}
+}